@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// snippetContext is the number of lines shown around the error line.
+const snippetContext = 2
+
+// writeEvalDiagnostic writes a human-readable diagnostic for a Nix evaluation
+// error, including a code snippet of where the error occurred.
+func writeEvalDiagnostic(w io.Writer, err *nixmodule.EvalError) {
+	fmt.Fprintf(w, "error: %s\n", indentLines(err.Message, "       "))
+	if len(err.OptionPath) > 0 {
+		fmt.Fprintf(w, "  option: %s\n", strings.Join(err.OptionPath, "."))
+	}
+
+	if err.Location.File != "" {
+		fmt.Fprintf(w, "  --> %s\n", err.Location)
+		writeSnippet(w, err.Location)
+	}
+
+	if len(err.Trace) > 0 {
+		fmt.Fprintln(w, "  trace:")
+		for _, frame := range err.Trace {
+			fmt.Fprintf(w, "    … %s\n", indentLines(frame.Message, "      "))
+			if frame.Location.File != "" {
+				fmt.Fprintf(w, "      at %s\n", frame.Location)
+			}
+		}
+	}
+}
+
+func writeSnippet(w io.Writer, loc nixmodule.Location) {
+	if loc.Line == 0 {
+		return
+	}
+
+	f, err := os.Open(loc.File)
+	if err != nil {
+		// The file may not exist, e.g. for expressions given as strings.
+		return
+	}
+	defer f.Close()
+
+	from := max(loc.Line-snippetContext, 1)
+	upto := loc.Line + snippetContext
+	width := len(fmt.Sprint(upto))
+
+	fmt.Fprintf(w, "  %*s |\n", width, "")
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= upto; n++ {
+		if n < from {
+			continue
+		}
+
+		line := scanner.Text()
+		fmt.Fprintf(w, "  %*d | %s\n", width, n, line)
+
+		if n == loc.Line && loc.Column > 0 {
+			fmt.Fprintf(w, "  %*s | %s^\n", width, "", caretPadding(line, loc.Column-1))
+		}
+	}
+}
+
+// caretPadding returns the whitespace that puts a caret under the given
+// column of line. Tabs are kept so that the caret lines up regardless of the
+// tab width.
+func caretPadding(line string, column int) string {
+	var b strings.Builder
+	for i, r := range line {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+func indentLines(s, indent string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+indent)
+}
@@ -2,8 +2,11 @@
 package example
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
 )
 
 // Config is the struct type for `config`.
@@ -11,107 +14,480 @@ type Config struct {
 	Examples Examples `json:"examples"`
 }
 
+// Validate returns an error if any value within c is out of its allowed range,
+// or if a required value isn't set.
+func (c Config) Validate() error {
+	var errs []error
+	if err := c.Examples.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("examples: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // Examples is the struct type for `config.examples`.
 type Examples struct {
 	Modules Modules `json:"modules"`
 }
 
+// Validate returns an error if any value within e is out of its allowed range,
+// or if a required value isn't set.
+func (e Examples) Validate() error {
+	var errs []error
+	if err := e.Modules.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("modules: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // Modules is the struct type for `config.examples.modules`.
 type Modules struct {
 	ComplexModule ComplexModule `json:"complexModule"`
 }
 
+// Validate returns an error if any value within m is out of its allowed range,
+// or if a required value isn't set.
+func (m Modules) Validate() error {
+	var errs []error
+	if err := m.ComplexModule.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("complexModule: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // ComplexModule is the struct type for `config.examples.modules.complexModule`.
 type ComplexModule struct {
 	// Enable: whether to enable example-module.
+	//
+	// Default: false
+	//
+	// Example: true
+	//
+	// Declared at example/module.nix:16.
 	Enable bool `json:"enable"`
 	// Package: example package option.
+	//
+	// Default:
+	//
+	//	pkgs.hello
+	//
+	// Declared at example/module.nix:292.
 	Package string `json:"package"`
 	// Anything: example anything option.
+	//
+	// Default: null
+	//
+	// Declared at example/module.nix:122.
 	Anything any `json:"anything"`
 	// Attrs: example attrs option (treated as map[string]any).
+	//
+	// Default: {}
+	//
+	// Declared at example/module.nix:128.
 	Attrs map[string]any `json:"attrs"`
 	// Bool: example boolean option.
+	//
+	// Default: false
+	//
+	// Declared at example/module.nix:111.
 	Bool bool `json:"bool"`
+	// Coerced: example coercedTo option (a string or a list of strings).
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:100.
+	Coerced []string `json:"coerced"`
 	// Either: example either option (int or string).
+	//
+	// Default: 42
+	//
+	// Declared at example/module.nix:163.
 	Either EitherJSON `json:"either"`
 	// EitherSubmodule: submodule or path to the submodule.
+	//
+	// Default: "/run/secrets/submodule.json"
+	//
+	// Declared at example/module.nix:251.
 	EitherSubmodule EitherSubmoduleJSON `json:"eitherSubmodule"`
 	// Enum: example enum option.
+	//
+	// Default: "a"
+	//
+	// Declared at example/module.nix:134.
 	Enum Enum `json:"enum"`
+	// Hostname: example strMatching option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:69.
+	Hostname Hostname `json:"hostname"`
+	// IniValue: example pkgs.formats.ini option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:90.
+	IniValue map[string]map[string]FormatValue `json:"iniValue"`
+	// IntEnum: example enum option of integers.
+	//
+	// Default: 1
+	//
+	// Declared at example/module.nix:144.
+	IntEnum IntEnum `json:"intEnum"`
 	// Internal: example internal option.
+	//
+	// Default: false
+	//
+	// Declared at example/module.nix:285.
 	Internal bool `json:"internal"`
+	// JsonValue: example pkgs.formats.json option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:85.
+	JsonValue FormatValue `json:"jsonValue"`
 	// Lines: example lines option (treated as string).
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Example: "Hello, world!\nHello, 世界!\n"
+	//
+	// Declared at example/module.nix:55.
 	Lines string `json:"lines"`
+	// MixedEnum: example enum option of a boolean and a string.
+	//
+	// Default: "auto"
+	//
+	// Declared at example/module.nix:154.
+	MixedEnum MixedEnum `json:"mixedEnum"`
 	// Nullable: example nullable string option.
+	//
+	// Default: null
+	//
+	// Declared at example/module.nix:180.
 	Nullable *string `json:"nullable"`
 	// NullableSubmodule: example nullable submodule option.
+	//
+	// Default: null
+	//
+	// Declared at example/module.nix:239.
 	NullableSubmodule *NullableSubmodule `json:"nullableSubmodule"`
 	// Number: example number option.
+	//
+	// Default: 42
+	//
+	// Example: 42
+	//
+	// Declared at example/module.nix:24.
 	Number int `json:"number"`
 	// Numbers: example for various ints.* options.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:31.
 	Numbers Numbers `json:"numbers"`
 	// OneOf: example oneOf option (int or string or bool).
+	//
+	// Default: false
+	//
+	// Declared at example/module.nix:169.
 	OneOf OneOfJSON `json:"oneOf"`
 	// Path: example path option (treated as string).
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Example: "/etc/nixos/configuration.nix"
+	//
+	// Declared at example/module.nix:105.
 	Path string `json:"path"`
 	// Port: example port number option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:64.
 	Port uint16 `json:"port"`
+	// Settings: example freeform settings option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:74.
+	Settings Settings `json:"settings"`
 	// String: example string option.
+	//
+	// Default: "Hello, World!"
+	//
+	// Declared at example/module.nix:18.
 	String string `json:"string"`
 	// StringAttrs: map[string]string option.
+	//
+	// Default: {"hello":"world"}
+	//
+	// Declared at example/module.nix:186.
 	StringAttrs map[string]string `json:"stringAttrs"`
 	// StringList: list of strings.
+	//
+	// Default: ["Hello","World"]
+	//
+	// Declared at example/module.nix:194.
 	StringList []string `json:"stringList"`
 	// Submodule: example submodule option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:203.
 	Submodule Submodule `json:"submodule"`
 	// SubmoduleList: example list of submodules.
+	//
+	// Default: [{"enable":true},{"enable":false}]
+	//
+	// Declared at example/module.nix:266.
 	SubmoduleList []SubmoduleList `json:"submoduleList"`
 	// SubmoduleSelfRef: example submodule option that references its own name.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:223.
 	SubmoduleSelfRef SubmoduleSelfRef `json:"submoduleSelfRef"`
+	// Tree: example recursive option (a tree of strings).
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:95.
+	Tree TreeValue `json:"tree"`
 	// Uniq: example unique string option.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:117.
 	Uniq string `json:"uniq"`
+	// Untyped: example option declared without a type.
+	//
+	// Default: ["a","b"]
+	//
+	// Declared at example/module.nix:299.
+	Untyped []string `json:"untyped"`
+}
+
+// Validate returns an error if any value within c is out of its allowed range,
+// or if a required value isn't set.
+func (c ComplexModule) Validate() error {
+	var errs []error
+	if c.Coerced == nil {
+		errs = append(errs, errors.New("coerced: required but not set"))
+	}
+	if err := c.Hostname.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("hostname: %w", err))
+	}
+	if c.IniValue == nil {
+		errs = append(errs, errors.New("iniValue: required but not set"))
+	}
+	if err := c.Numbers.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("numbers: %w", err))
+	}
+	return errors.Join(errs...)
 }
 
 // SubmoduleSelfRef is the struct type for `config.examples.modules.complexModule.submoduleSelfRef`.
 type SubmoduleSelfRef struct {
 	// CurrentName: name of the submodule.
+	//
+	// Default: "‹name›"
+	//
+	// Declared at example/module.nix:228.
 	CurrentName string `json:"currentName"`
 }
 
 // SubmoduleList is the struct type for `config.examples.modules.complexModule.submoduleList`.
 type SubmoduleList struct {
 	// Enable: whether to enable submodule-list.
+	//
+	// Default: false
+	//
+	// Example: true
+	//
+	// Declared at example/module.nix:270.
 	Enable bool `json:"enable"`
 }
 
 // Submodule is the struct type for `config.examples.modules.complexModule.submodule`.
 type Submodule struct {
 	// InnerNullable: example nullable string option.
+	//
+	// Default: null
+	//
+	// Declared at example/module.nix:213.
 	InnerNullable *string `json:"innerNullable"`
 	// InnerString: example string option.
+	//
+	// Default: "Hello, World!"
+	//
+	// Declared at example/module.nix:207.
 	InnerString string `json:"innerString"`
 }
 
+// Settings is the struct type for `config.examples.modules.complexModule.settings`.
+type Settings struct {
+	// Name: declared setting.
+	//
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:77.
+	Name string `json:"name"`
+	// Extra contains the values of undeclared attributes, which are
+	// allowed by the freeform type of `config.examples.modules.complexModule.settings`.
+	Extra map[string]string `json:"-"`
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for [Settings].
+// Undeclared attributes are kept in Extra.
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	type declared Settings
+	if err := json.Unmarshal(data, (*declared)(s)); err != nil {
+		return err
+	}
+
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return err
+	}
+	for _, k := range []string{"name"} {
+		delete(attrs, k)
+	}
+
+	s.Extra = nil
+	for k, v := range attrs {
+		var value string
+		if err := json.Unmarshal(v, &value); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		if s.Extra == nil {
+			s.Extra = make(map[string]string, len(attrs))
+		}
+		s.Extra[k] = value
+	}
+	return nil
+}
+
+// MarshalJSON implements the [json.Marshaler] interface for [Settings].
+// Attributes in Extra are marshaled along with the declared ones.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	type declared Settings
+	data, err := json.Marshal(declared(s))
+	if err != nil || len(s.Extra) == 0 {
+		return data, err
+	}
+
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	for k, v := range s.Extra {
+		if _, ok := attrs[k]; ok {
+			// Declared attributes take precedence.
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		attrs[k] = b
+	}
+	return json.Marshal(attrs)
+}
+
 // Numbers is the struct type for `config.examples.modules.complexModule.numbers`.
 type Numbers struct {
-	Between  int         `json:"between"`
-	Float    float64     `json:"float"`
-	Int      int         `json:"int"`
-	Number   json.Number `json:"number"`
-	Positive uint        `json:"positive"`
-	S16      int16       `json:"s16"`
-	S32      int32       `json:"s32"`
-	S8       int8        `json:"s8"`
-	U16      uint16      `json:"u16"`
-	U32      uint32      `json:"u32"`
-	U8       uint8       `json:"u8"`
-	Unsigned uint        `json:"unsigned"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:44.
+	Between Between `json:"between"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:35.
+	Float float64 `json:"float"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:34.
+	Int int `json:"int"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:36.
+	Number json.Number `json:"number"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:47.
+	NumberBetween NumberBetween `json:"numberBetween"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:49.
+	NumberNonnegative NumberNonnegative `json:"numberNonnegative"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:48.
+	NumberPositive NumberPositive `json:"numberPositive"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:46.
+	Positive Positive `json:"positive"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:41.
+	S16 int16 `json:"s16"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:43.
+	S32 int32 `json:"s32"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:39.
+	S8 int8 `json:"s8"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:40.
+	U16 uint16 `json:"u16"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:42.
+	U32 uint32 `json:"u32"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:38.
+	U8 uint8 `json:"u8"`
+	// Required: it has no default value, so it must be set.
+	//
+	// Declared at example/module.nix:45.
+	Unsigned uint `json:"unsigned"`
+}
+
+// Validate returns an error if any value within n is out of its allowed range,
+// or if a required value isn't set.
+func (n Numbers) Validate() error {
+	var errs []error
+	if err := n.Between.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("between: %w", err))
+	}
+	if err := n.NumberBetween.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("numberBetween: %w", err))
+	}
+	if err := n.NumberNonnegative.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("numberNonnegative: %w", err))
+	}
+	if err := n.NumberPositive.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("numberPositive: %w", err))
+	}
+	if err := n.Positive.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("positive: %w", err))
+	}
+	return errors.Join(errs...)
 }
 
 // NullableSubmodule is the struct type for `config.examples.modules.complexModule.nullableSubmodule`.
 type NullableSubmodule struct {
 	// Enable: whether to enable nullable-submodule.
+	//
+	// Default: false
+	//
+	// Example: true
+	//
+	// Declared at example/module.nix:243.
 	Enable bool `json:"enable"`
 }
 
@@ -146,11 +522,11 @@ type EitherJSON struct{ Value Either }
 
 // UnmarshalJSON implements the [json.Unmarshaler] interface for [Either].
 func (e *EitherJSON) UnmarshalJSON(data []byte) error {
-	v, err := unmarshalEither(data)
+	_v, err := unmarshalEither(data)
 	if err != nil {
 		return err
 	}
-	e.Value = v
+	e.Value = _v
 	return nil
 }
 
@@ -184,6 +560,9 @@ type EitherSubmodulePath string
 
 // EitherSubmoduleSubmodule is one of the types that satisfy [EitherSubmodule].
 type EitherSubmoduleSubmodule struct {
+	// Default: "world"
+	//
+	// Declared at example/module.nix:255.
 	Hello string `json:"hello"`
 }
 
@@ -199,6 +578,9 @@ func NewEitherSubmodulePath(e string) EitherSubmodule {
 
 // NewEitherSubmoduleSubmodule constructs a value of type `submodule` that satisfies [EitherSubmodule].
 func NewEitherSubmoduleSubmodule(e struct {
+	// Default: "world"
+	//
+	// Declared at example/module.nix:255.
 	Hello string `json:"hello"`
 }) EitherSubmodule {
 	return EitherSubmoduleSubmodule(e)
@@ -209,11 +591,11 @@ type EitherSubmoduleJSON struct{ Value EitherSubmodule }
 
 // UnmarshalJSON implements the [json.Unmarshaler] interface for [EitherSubmodule].
 func (e *EitherSubmoduleJSON) UnmarshalJSON(data []byte) error {
-	v, err := unmarshalEitherSubmodule(data)
+	_v, err := unmarshalEitherSubmodule(data)
 	if err != nil {
 		return err
 	}
-	e.Value = v
+	e.Value = _v
 	return nil
 }
 
@@ -230,6 +612,9 @@ func unmarshalEitherSubmodule(data json.RawMessage) (EitherSubmodule, error) {
 	}
 
 	var v1 struct {
+		// Default: "world"
+		//
+		// Declared at example/module.nix:255.
 		Hello string `json:"hello"`
 	}
 	if err := json.Unmarshal(data, &v1); err == nil {
@@ -248,6 +633,207 @@ const (
 	EnumC Enum = "c"
 )
 
+// Hostname is the type for `config.examples.modules.complexModule.hostname`.
+// Its values must match the pattern `[a-z][a-z0-9-]*`.
+type Hostname string
+
+var hostnamePattern = regexp.MustCompilePOSIX("^([a-z][a-z0-9-]*)$")
+
+// Validate returns an error if h doesn't match its pattern.
+func (h Hostname) Validate() error {
+	if !hostnamePattern.MatchString(string(h)) {
+		return fmt.Errorf("%q does not match the pattern %q", string(h), "[a-z][a-z0-9-]*")
+	}
+	return nil
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for [Hostname].
+// It returns an error if the value doesn't match the pattern.
+func (h *Hostname) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if err := Hostname(s).Validate(); err != nil {
+		return err
+	}
+	*h = Hostname(s)
+	return nil
+}
+
+// FormatValue is a value of a pkgs.formats type, such as a JSON, YAML or TOML
+// value. At most one of its fields is set. If none is, the value is null.
+type FormatValue struct {
+	Bool   *bool
+	Int    *int64
+	Float  *float64
+	String *string
+	List   []FormatValue
+	Attrs  map[string]FormatValue
+}
+
+// IsNull returns true if v is null.
+func (v FormatValue) IsNull() bool {
+	return v.Bool == nil && v.Int == nil && v.Float == nil && v.String == nil &&
+		v.List == nil && v.Attrs == nil
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for [FormatValue].
+func (v *FormatValue) UnmarshalJSON(data []byte) error {
+	*v = FormatValue{}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return errors.New("failed to unmarshal FormatValue: empty value")
+	}
+
+	switch data[0] {
+	case 'n':
+		return nil
+	case 't', 'f':
+		return json.Unmarshal(data, &v.Bool)
+	case '"':
+		return json.Unmarshal(data, &v.String)
+	case '[':
+		v.List = []FormatValue{}
+		return json.Unmarshal(data, &v.List)
+	case '{':
+		v.Attrs = map[string]FormatValue{}
+		return json.Unmarshal(data, &v.Attrs)
+	default:
+		if bytes.ContainsAny(data, ".eE") {
+			return json.Unmarshal(data, &v.Float)
+		}
+		return json.Unmarshal(data, &v.Int)
+	}
+}
+
+// MarshalJSON implements the [json.Marshaler] interface for [FormatValue].
+func (v FormatValue) MarshalJSON() ([]byte, error) {
+	switch {
+	case v.Bool != nil:
+		return json.Marshal(*v.Bool)
+	case v.Int != nil:
+		return json.Marshal(*v.Int)
+	case v.Float != nil:
+		return json.Marshal(*v.Float)
+	case v.String != nil:
+		return json.Marshal(*v.String)
+	case v.List != nil:
+		return json.Marshal(v.List)
+	case v.Attrs != nil:
+		return json.Marshal(v.Attrs)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+// IntEnum is the enum type for `config.examples.modules.complexModule.intEnum`.
+type IntEnum int
+
+const (
+	IntEnum1 IntEnum = 1
+	IntEnum2 IntEnum = 2
+	IntEnum3 IntEnum = 3
+)
+
+// MixedEnum is the enum type for `config.examples.modules.complexModule.mixedEnum`.
+// Its values are of different types, so each value holds its JSON encoding.
+type MixedEnum string
+
+const (
+	MixedEnumFalse MixedEnum = "false"
+	MixedEnumAuto  MixedEnum = "\"auto\""
+)
+
+// MarshalJSON implements the [json.Marshaler] interface for [MixedEnum].
+// The zero value is marshaled as null.
+func (m MixedEnum) MarshalJSON() ([]byte, error) {
+	if m == "" {
+		return []byte("null"), nil
+	}
+	return []byte(m), nil
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for [MixedEnum].
+// It returns an error if the value isn't one of the enum values.
+func (m *MixedEnum) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	switch v := MixedEnum(b); v {
+	case MixedEnumFalse, MixedEnumAuto:
+		*m = v
+		return nil
+	}
+	return fmt.Errorf("%s is not a valid MixedEnum", data)
+}
+
+// Between is the type for `config.examples.modules.complexModule.numbers.between`.
+// Its values must be between 1 and 10 (inclusive).
+type Between uint8
+
+// Validate returns an error if b is not between 1 and 10 (inclusive).
+func (b Between) Validate() error {
+	if b < 1 || b > 10 {
+		return fmt.Errorf("%v is not between 1 and 10 (inclusive)", b)
+	}
+	return nil
+}
+
+// NumberBetween is the type for `config.examples.modules.complexModule.numbers.numberBetween`.
+// Its values must be between 0.5 and 1.5 (inclusive).
+type NumberBetween float64
+
+// Validate returns an error if n is not between 0.5 and 1.5 (inclusive).
+func (n NumberBetween) Validate() error {
+	if n < 0.5 || n > 1.5 {
+		return fmt.Errorf("%v is not between 0.5 and 1.5 (inclusive)", n)
+	}
+	return nil
+}
+
+// NumberNonnegative is the type for `config.examples.modules.complexModule.numbers.numberNonnegative`.
+// Its values must be nonnegative.
+type NumberNonnegative float64
+
+// Validate returns an error if n is not nonnegative.
+func (n NumberNonnegative) Validate() error {
+	if n < 0 {
+		return fmt.Errorf("%v is not nonnegative", n)
+	}
+	return nil
+}
+
+// NumberPositive is the type for `config.examples.modules.complexModule.numbers.numberPositive`.
+// Its values must be positive.
+type NumberPositive float64
+
+// Validate returns an error if n is not positive.
+func (n NumberPositive) Validate() error {
+	if n <= 0 {
+		return fmt.Errorf("%v is not positive", n)
+	}
+	return nil
+}
+
+// Positive is the type for `config.examples.modules.complexModule.numbers.positive`.
+// Its values must be positive.
+type Positive uint
+
+// Validate returns an error if p is not positive.
+func (p Positive) Validate() error {
+	if p == 0 {
+		return fmt.Errorf("%v is not positive", p)
+	}
+	return nil
+}
+
 // OneOf describes the `either` type for `config.examples.modules.complexModule.oneOf`.
 type OneOf interface {
 	isOneOf()
@@ -299,11 +885,11 @@ type OneOfJSON struct{ Value OneOf }
 
 // UnmarshalJSON implements the [json.Unmarshaler] interface for [OneOf].
 func (o *OneOfJSON) UnmarshalJSON(data []byte) error {
-	v, err := unmarshalOneOf(data)
+	_v, err := unmarshalOneOf(data)
 	if err != nil {
 		return err
 	}
-	o.Value = v
+	o.Value = _v
 	return nil
 }
 
@@ -336,3 +922,65 @@ func unmarshalOneOf(data json.RawMessage) (OneOf, error) {
 
 	return nil, errors.New("failed to unmarshal OneOf: unknown type received")
 }
+
+// Tree describes the `either` type for `config.examples.modules.complexModule.tree`.
+type Tree interface {
+	isTree()
+}
+
+// TreeStr is one of the types that satisfy [Tree].
+type TreeStr string
+
+// TreeRef is one of the types that satisfy [Tree].
+type TreeRef TreeValue
+
+func (t TreeStr) isTree() {
+}
+func (t TreeRef) isTree() {
+}
+
+// NewTreeStr constructs a value of type `str` that satisfies [Tree].
+func NewTreeStr(t string) Tree {
+	return TreeStr(t)
+}
+
+// NewTreeRef constructs a value of type `ref` that satisfies [Tree].
+func NewTreeRef(t TreeValue) Tree {
+	return TreeRef(t)
+}
+
+// TreeJSON wraps [Tree] and implements the json.Unmarshaler interface.
+type TreeJSON struct{ Value Tree }
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for [Tree].
+func (t *TreeJSON) UnmarshalJSON(data []byte) error {
+	_v, err := unmarshalTree(data)
+	if err != nil {
+		return err
+	}
+	t.Value = _v
+	return nil
+}
+
+// MarshalJSON implements the [json.Marshaler] interface for [Tree].
+func (t TreeJSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Value)
+}
+
+func unmarshalTree(data json.RawMessage) (Tree, error) {
+
+	var v0 string
+	if err := json.Unmarshal(data, &v0); err == nil {
+		return TreeStr(v0), nil
+	}
+
+	var v1 TreeValue
+	if err := json.Unmarshal(data, &v1); err == nil {
+		return TreeRef(v1), nil
+	}
+
+	return nil, errors.New("failed to unmarshal Tree: unknown type received")
+}
+
+// TreeValue is the recursive type for `config.examples.modules.complexModule.tree`.
+type TreeValue map[string]TreeJSON
@@ -7,6 +7,10 @@
 
 with lib;
 
+let
+  # treeType refers to itself, which is dumped as a ref.
+  treeType = types.attrsOf (types.either types.str treeType);
+in
 {
   options.examples.modules.complexModule = {
     enable = mkEnableOption "example-module";
@@ -40,6 +44,9 @@ with lib;
           between = mkOption { type = types.ints.between 1 10; };
           unsigned = mkOption { type = types.ints.unsigned; };
           positive = mkOption { type = types.ints.positive; };
+          numberBetween = mkOption { type = types.numbers.between 0.5 1.5; };
+          numberPositive = mkOption { type = types.numbers.positive; };
+          numberNonnegative = mkOption { type = types.numbers.nonnegative; };
         };
       };
       description = "An example for various ints.* options";
@@ -59,6 +66,42 @@ with lib;
       description = "An example port number option";
     };
 
+    hostname = mkOption {
+      type = types.strMatching "[a-z][a-z0-9-]*";
+      description = "An example strMatching option";
+    };
+
+    settings = mkOption {
+      type = types.submodule {
+        freeformType = types.attrsOf types.str;
+        options.name = mkOption {
+          type = types.str;
+          description = "A declared setting";
+        };
+      };
+      description = "An example freeform settings option";
+    };
+
+    jsonValue = mkOption {
+      type = (pkgs.formats.json { }).type;
+      description = "An example pkgs.formats.json option";
+    };
+
+    iniValue = mkOption {
+      type = (pkgs.formats.ini { }).type;
+      description = "An example pkgs.formats.ini option";
+    };
+
+    tree = mkOption {
+      type = treeType;
+      description = "An example recursive option (a tree of strings)";
+    };
+
+    coerced = mkOption {
+      type = types.coercedTo types.str (s: [ s ]) (types.listOf types.str);
+      description = "An example coercedTo option (a string or a list of strings)";
+    };
+
     path = mkOption {
       type = types.path;
       example = "/etc/nixos/configuration.nix";
@@ -98,6 +141,25 @@ with lib;
       description = "An example enum option";
     };
 
+    intEnum = mkOption {
+      type = types.enum [
+        1
+        2
+        3
+      ];
+      default = 1;
+      description = "An example enum option of integers";
+    };
+
+    mixedEnum = mkOption {
+      type = types.enum [
+        false
+        "auto"
+      ];
+      default = "auto";
+      description = "An example enum option of a boolean and a string";
+    };
+
     either = mkOption {
       type = types.either types.int types.str;
       default = 42;
@@ -230,8 +292,17 @@ with lib;
     package = mkOption {
       type = types.package;
       default = pkgs.hello;
+      defaultText = literalExpression "pkgs.hello";
       description = "An example package option";
     };
+
+    untyped = mkOption {
+      default = [
+        "a"
+        "b"
+      ];
+      description = "An example option declared without a type";
+    };
   };
 
   config = {
@@ -252,15 +323,43 @@ with lib;
         between = 5;
         unsigned = 42;
         positive = 42;
+        numberBetween = 1.25;
+        numberPositive = 2;
+        numberNonnegative = 0;
       };
       lines = "Hello, world!\nHello, 世界!\n";
       port = 80;
+      hostname = "example-host";
+      coerced = "coerced";
+      jsonValue = {
+        list = [
+          1
+          2.5
+          "three"
+          null
+        ];
+        nested.enable = true;
+      };
+      iniValue = {
+        section.key = "value";
+        section.number = 42;
+      };
+      tree = {
+        leaf = "Hello";
+        branch.leaf = "World";
+      };
+      settings = {
+        name = "example";
+        greeting = "Hello, World!";
+      };
       path = "/etc/nixos/configuration.nix";
       bool = false;
       uniq = "Hello, World!";
       anything = null;
       attrs = { };
       enum = "a";
+      intEnum = 2;
+      mixedEnum = false;
       either = 42;
       oneOf = false;
       nullable = null;
@@ -27,6 +27,9 @@ func TestUnmarshal(t *testing.T) {
 	err = json.Unmarshal(nixConfig, &m)
 	assert.NoError(t, err, "json.Unmarshal failed")
 
+	err = m.Validate()
+	assert.NoError(t, err, "m.Validate failed")
+
 	goConfig, err := json.Marshal(m)
 	assert.NoError(t, err, "json.Marshal failed")
 
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os/exec"
+	"slices"
 
 	"github.com/go-json-experiment/json"
 	"github.com/urfave/cli/v3"
@@ -11,8 +13,9 @@ import (
 )
 
 type flakeInfo struct {
-	Locks flakeLocks `json:"locks"`
-	URL   string     `json:"url"`
+	Locks  flakeLocks     `json:"locks"`
+	Locked flakeLockedRef `json:"locked"`
+	URL    string         `json:"url"`
 }
 
 type flakeLocks struct {
@@ -21,10 +24,12 @@ type flakeLocks struct {
 }
 
 type flakeLockNode struct {
-	Locked struct {
-		NARHash      string `json:"narHash"`
-		LastModified int    `json:"lastModified"`
-	} `json:"locked"`
+	Locked flakeLockedRef `json:"locked"`
+}
+
+type flakeLockedRef struct {
+	NARHash      string `json:"narHash"`
+	LastModified int    `json:"lastModified"`
 }
 
 type flakeNixpkgsData struct {
@@ -59,6 +64,21 @@ func (f flakeInfo) String() string {
 	return f.URL
 }
 
+// narHashes returns the narHash of the flake itself followed by the narHashes
+// of its locked inputs, or false if the flake itself has no narHash.
+func (f flakeInfo) narHashes() ([]string, bool) {
+	if f.Locked.NARHash == "" {
+		return nil, false
+	}
+	hashes := []string{f.Locked.NARHash}
+	for _, name := range slices.Sorted(maps.Keys(f.Locks.Nodes)) {
+		if hash := f.Locks.Nodes[name].Locked.NARHash; hash != "" {
+			hashes = append(hashes, name+"="+hash)
+		}
+	}
+	return hashes, true
+}
+
 func (f flakeInfo) flakeExpr() nixmodule.NixExpr {
 	return nixmodule.NixExpr(fmt.Sprintf(`builtins.getFlake %q`, f.URL))
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -27,7 +28,15 @@ func main() {
 	defer cancel()
 
 	if err := cmd.Run(ctx, os.Args); err != nil {
-		slog.ErrorContext(ctx, err.Error())
+		// Evaluation errors are only printed as diagnostics, which already
+		// contain the message.
+		var evalErr *nixmodule.EvalError
+		if errors.As(err, &evalErr) {
+			writeEvalDiagnostic(os.Stderr, evalErr)
+		} else {
+			slog.ErrorContext(ctx, err.Error())
+		}
+
 		os.Exit(1)
 	}
 }
@@ -38,6 +47,7 @@ var cmd = &cli.Command{
 	ArgsUsage: "<.#flake.path.to.module|/path/to/module> [output-file]",
 	Before:    appBefore,
 	Action:    appAction,
+	After:     appAfter,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:    "config-file",
@@ -94,6 +104,28 @@ var cmd = &cli.Command{
 			Usage:   "the type name of the generated root Go struct",
 			Value:   "Config",
 		},
+		&cli.BoolFlag{
+			Name:  "go-accept-coerced",
+			Usage: "make the Go types of coercedTo options accept both the original and the final type",
+		},
+		&cli.StringFlag{
+			Name:      "go-internal",
+			Usage:     "what to do with internal options: keep, skip or separate (into their own embedded struct)",
+			Value:     "keep",
+			Validator: enumValidator("keep", "skip", "separate"),
+		},
+		&cli.StringFlag{
+			Name:      "go-invisible",
+			Usage:     "what to do with options hidden from the documentation: keep, skip or separate",
+			Value:     "keep",
+			Validator: enumValidator("keep", "skip", "separate"),
+		},
+		&cli.StringFlag{
+			Name:      "go-read-only",
+			Usage:     "what to do with read-only options: keep, skip or separate",
+			Value:     "keep",
+			Validator: enumValidator("keep", "skip", "separate"),
+		},
 		&cli.StringFlag{
 			Name:    "options-path",
 			Aliases: []string{"O"},
@@ -121,11 +153,48 @@ var cmd = &cli.Command{
 			Usage: "add current flake (as self) to special-args, errors if not in a flake",
 			Value: true,
 		},
+		&cli.StringSliceFlag{
+			Name:    "module",
+			Aliases: []string{"m"},
+			Usage:   "module to evaluate, may be repeated to evaluate several modules together; if given, the only argument is the output file",
+		},
+		&cli.BoolFlag{
+			Name:    "evaluated",
+			Aliases: []string{"e"},
+			Usage:   "treat the module as an already-evaluated option set, such as .#nixosConfigurations.host.options",
+		},
 		&cli.BoolFlag{
 			Name:    "expr",
 			Aliases: []string{"E"},
 			Usage:   "treat module-path as a Nix expression",
 		},
+		&cli.StringFlag{
+			Name:      "evaluator",
+			Usage:     "the Nix evaluator to use: nix-instantiate, nix (nix eval) or repl (a single nix repl session)",
+			Value:     "nix-instantiate",
+			Validator: enumValidator("nix-instantiate", "nix", "repl"),
+		},
+		&cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: "directory to cache module dumps in (default: the user cache directory)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "don't cache module dumps",
+		},
+		&cli.IntFlag{
+			Name:  "shard-depth",
+			Usage: "split the option tree into shards of this depth and dump them in parallel, 0 to disable",
+		},
+		&cli.IntFlag{
+			Name:    "jobs",
+			Aliases: []string{"j"},
+			Usage:   "number of shards to dump at a time (default: number of CPUs)",
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail if any option could not be dumped precisely",
+		},
 		&cli.BoolFlag{
 			Name:    "verbose",
 			Aliases: []string{"v"},
@@ -186,14 +255,49 @@ func appBefore(ctx context.Context, cmd *cli.Command) error {
 	strcases.AddPascalSpecials(initials)
 	strcases.SetPascalWords(initialsReplace)
 
+	// Set the default evaluator so that flag validations also use it.
+	switch cmd.String("evaluator") {
+	case "nix-instantiate":
+		nixmodule.DefaultEvaluator = nixmodule.NixInstantiate{}
+	case "nix":
+		nixmodule.DefaultEvaluator = nixmodule.NixEval{}
+	case "repl":
+		nixmodule.DefaultEvaluator = &nixmodule.NixRepl{}
+	}
+
+	slog.DebugContext(ctx,
+		"using evaluator",
+		"evaluator", cmd.String("evaluator"))
+
+	return nil
+}
+
+func appAfter(ctx context.Context, cmd *cli.Command) error {
+	if closer, ok := nixmodule.DefaultEvaluator.(io.Closer); ok {
+		return closer.Close()
+	}
 	return nil
 }
 
 func appAction(ctx context.Context, cmd *cli.Command) error {
-	if !cmd.Args().Present() {
+	// Several modules can only be given using --module, so that the output
+	// file is never mistaken for a module or the other way around.
+	modules := cmd.StringSlice("module")
+	output := cmd.Args().Get(0)
+	maxArgs := 1
+	if len(modules) == 0 {
+		modules = cmd.Args().Slice()[:min(1, cmd.Args().Len())]
+		output = cmd.Args().Get(1)
+		maxArgs = 2
+	}
+
+	if len(modules) == 0 {
 		cli.ShowAppHelp(cmd)
 		return cli.Exit("invalid usage", 1)
 	}
+	if cmd.Args().Len() > maxArgs {
+		return cli.Exit("too many arguments: use --module to evaluate several modules together", 1)
+	}
 
 	flake, err := currentFlake(ctx, cmd)
 	if err != nil {
@@ -208,18 +312,23 @@ func appAction(ctx context.Context, cmd *cli.Command) error {
 	}
 
 	var input nixmodule.ModuleInput
-	if cmd.Bool("expr") {
-		input = nixmodule.ModuleExpr(cmd.Args().Get(0))
-	} else {
-		arg := cmd.Args().Get(0)
-		if flakePath, ok := strings.CutPrefix(arg, ".#"); ok {
-			input = nixmodule.ModuleExpr(fmt.Sprintf(
-				"(%s).%s",
-				flake.flakeExpr(), flakePath,
-			))
-		} else {
-			input = nixmodule.ModulePath(arg)
+	switch {
+	case cmd.Bool("evaluated"):
+		if len(modules) > 1 {
+			return fmt.Errorf("only one evaluated option set can be given")
 		}
+		input, err = optionsInput(cmd, flake, modules[0])
+		if err != nil {
+			return err
+		}
+	case len(modules) == 1:
+		input = moduleInput(cmd, flake, modules[0])
+	default:
+		inputs := make(nixmodule.ModuleList, len(modules))
+		for i, module := range modules {
+			inputs[i] = moduleInput(cmd, flake, module)
+		}
+		input = inputs
 	}
 
 	pkgsExpr, err := pkgsExpr(ctx, cmd, pkgsOpts{Flake: flake})
@@ -251,16 +360,49 @@ func appAction(ctx context.Context, cmd *cli.Command) error {
 		optionsPath = strings.Split(strPath, ".")
 	}
 
-	module, err := nixmodule.DumpModule(ctx, input,
+	dumpOpts := nixmodule.DumpModuleOpts{
 		nixmodule.DumpModuleWithPkgs(pkgsExpr),
 		nixmodule.DumpModuleWithSpecialArgs(specialArgs),
-		nixmodule.DumpModuleWithOptionsPath(optionsPath))
+		nixmodule.DumpModuleWithOptionsPath(optionsPath),
+	}
+
+	// Make locations relative, so that dumps are the same wherever the
+	// modules are checked out, even within the Nix store.
+	var locationRoots []nixmodule.NixExpr
+	if wd, err := os.Getwd(); err == nil {
+		locationRoots = append(locationRoots, nixmodule.NixExpr(fmt.Sprintf("%q", wd)))
+	}
+	if flake != nil {
+		locationRoots = append(locationRoots, flake.flakeExprToPath("outPath"))
+	}
+	dumpOpts.Add(nixmodule.DumpModuleWithLocationRoots(locationRoots...))
+
+	if depth := cmd.Int("shard-depth"); depth > 0 {
+		dumpOpts.Add(nixmodule.DumpModuleWithShards(int(depth), int(cmd.Int("jobs"))))
+	}
+
+	if cache, ok := moduleCache(ctx, cmd, flake, modules); ok {
+		dumpOpts.Add(nixmodule.DumpModuleWithCache(cache))
+	}
+
+	module, warnings, err := nixmodule.DumpModule(ctx, input, dumpOpts...)
 	if err != nil {
 		return err
 	}
 
+	for _, warning := range warnings {
+		slog.WarnContext(ctx,
+			warning.Reason,
+			"option", strings.Join(warning.Path, "."),
+			"type", warning.Type)
+	}
+
+	if cmd.Bool("strict") && len(warnings) > 0 {
+		return fmt.Errorf("strict mode: module has %d warnings", len(warnings))
+	}
+
 	var o io.Writer = os.Stdout
-	if output := cmd.Args().Get(1); output != "" {
+	if output != "" {
 		if filepath.Ext(output) == "" {
 			output += "." + cmd.String("format")
 		}
@@ -285,7 +427,16 @@ func appAction(ctx context.Context, cmd *cli.Command) error {
 		}
 	case "go":
 		goPackage := cmd.String("go-package")
-		goOpts := nixmod2go.Opts{RootName: cmd.String("go-type-name")}
+		goOpts := nixmod2go.Opts{
+			RootName:      cmd.String("go-type-name"),
+			AcceptCoerced: cmd.Bool("go-accept-coerced"),
+			Internal:      nixmod2go.FilterMode(cmd.String("go-internal")),
+			Invisible:     nixmod2go.FilterMode(cmd.String("go-invisible")),
+			ReadOnly:      nixmod2go.FilterMode(cmd.String("go-read-only")),
+		}
+		if wd, err := os.Getwd(); err == nil {
+			goOpts.LocationRoot = wd
+		}
 
 		code, err := nixmod2go.Generate(module, goPackage, goOpts)
 		if err != nil {
@@ -302,6 +453,120 @@ func appAction(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+// moduleCache returns the cache to use for module dumps. Only the top-level
+// module files are hashed by the cache itself, so caching is only enabled if
+// the flake's narHash covers everything else that the modules may import.
+// Caching is also disabled if the dump depends on something else that cannot
+// be tracked, such as the Nixpkgs given by --pkgs, which may be any
+// expression.
+func moduleCache(ctx context.Context, cmd *cli.Command, flake *flakeInfo, modules []string) (nixmodule.Cache, bool) {
+	if cmd.Bool("no-cache") {
+		return nixmodule.Cache{}, false
+	}
+
+	dir := cmd.String("cache-dir")
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			slog.DebugContext(ctx, "cache disabled: no user cache directory", "err", err)
+			return nixmodule.Cache{}, false
+		}
+		dir = filepath.Join(userDir, "nixmod2go")
+	}
+
+	if flake == nil {
+		slog.DebugContext(ctx, "cache disabled: not in a flake, imported files cannot be tracked")
+		return nixmodule.Cache{}, false
+	}
+
+	hashes, ok := flake.narHashes()
+	if !ok {
+		slog.DebugContext(ctx, "cache disabled: flake has no narHash", "flake", flake.URL)
+		return nixmodule.Cache{}, false
+	}
+
+	if reason, ok := coveredByFlake(cmd, modules); !ok {
+		slog.DebugContext(ctx, "cache disabled: "+reason, "flake", flake.URL)
+		return nixmodule.Cache{}, false
+	}
+
+	cache := nixmodule.Cache{Dir: dir, ExtraKeys: hashes}
+
+	slog.DebugContext(ctx,
+		"caching module dumps",
+		"dir", cache.Dir,
+		"keys", cache.ExtraKeys)
+
+	return cache, true
+}
+
+// coveredByFlake returns true if the narHash of the flake changes along with
+// every file that the modules may import, which is only known for flake
+// outputs and files within a local flake. Otherwise, it returns why not.
+func coveredByFlake(cmd *cli.Command, modules []string) (string, bool) {
+	if cmd.Bool("expr") {
+		return "module expressions may import any file", false
+	}
+	if len(cmd.StringMap("special-args")) > 0 {
+		return "special-args may import any file", false
+	}
+	if cmd.String("pkgs") != "" {
+		return "pkgs may import any file", false
+	}
+
+	// Flake references with a scheme, such as github:owner/repo, have no
+	// local directory.
+	var dir string
+	if flake := cmd.String("flake"); !strings.Contains(flake, ":") {
+		abs, err := filepath.Abs(flake)
+		if err == nil {
+			dir = abs
+		}
+	}
+
+	for _, module := range modules {
+		if strings.HasPrefix(module, ".#") {
+			continue
+		}
+		abs, err := filepath.Abs(module)
+		if err != nil || dir == "" {
+			return fmt.Sprintf("module %s is not within the flake", module), false
+		}
+		if rel, err := filepath.Rel(dir, abs); err != nil || !filepath.IsLocal(rel) {
+			return fmt.Sprintf("module %s is not within the flake", module), false
+		}
+	}
+
+	return "", true
+}
+
+func moduleInput(cmd *cli.Command, flake *flakeInfo, arg string) nixmodule.ModuleInput {
+	if cmd.Bool("expr") {
+		return nixmodule.ModuleExpr(arg)
+	}
+	if flakePath, ok := strings.CutPrefix(arg, ".#"); ok {
+		return nixmodule.ModuleExpr(fmt.Sprintf(
+			"(%s).%s",
+			flake.flakeExpr(), flakePath,
+		))
+	}
+	return nixmodule.ModulePath(arg)
+}
+
+func optionsInput(cmd *cli.Command, flake *flakeInfo, arg string) (nixmodule.ModuleInput, error) {
+	if cmd.Bool("expr") {
+		return nixmodule.ModuleOptions(arg), nil
+	}
+	if flakePath, ok := strings.CutPrefix(arg, ".#"); ok {
+		return nixmodule.ModuleOptions(flake.flakeExprToPath(flakePath)), nil
+	}
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, fmt.Errorf("resolve options path: %w", err)
+	}
+	return nixmodule.ModuleOptions("import " + abs), nil
+}
+
 func enumValidator[T comparable](vs ...T) func(T) error {
 	return func(v T) error {
 		if slices.Index(vs, v) == -1 {
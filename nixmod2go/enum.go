@@ -0,0 +1,212 @@
+package nixmod2go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/gir/girgen/strcases"
+	"libdb.so/nixmod2go/nixmodule"
+
+	gen "github.com/moznion/gowrtr/generator"
+)
+
+// enumValue is a value of an enum type.
+type enumValue struct {
+	// Raw is the value as dumped from Nix.
+	Raw []byte
+	// JSON is the value as encoded by encoding/json.
+	JSON []byte
+	// Value is the value decoded the way encoding/json does.
+	Value any
+}
+
+// enumKind returns "string" or "int" if all values are of that type, or an
+// empty string if the values are of different types.
+func enumKind(values []enumValue) string {
+	kind := ""
+	for i, v := range values {
+		var k string
+		switch v.Value.(type) {
+		case string:
+			k = "string"
+		case float64:
+			if _, err := strconv.ParseInt(string(v.Raw), 10, 64); err == nil {
+				k = "int"
+			}
+		}
+		if k == "" || (i > 0 && k != kind) {
+			return ""
+		}
+		kind = k
+	}
+	return kind
+}
+
+// enumValueName returns the name of the constant of an enum value, without the
+// name of the enum type.
+func enumValueName(v enumValue) string {
+	switch value := v.Value.(type) {
+	case nil:
+		return "Null"
+	case bool:
+		return strcases.SnakeToGo(true, strconv.FormatBool(value))
+	case string:
+		// Drop the characters that can't be within identifiers, such as in
+		// "<auto>".
+		name := strings.Map(func(r rune) rune {
+			if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, parseName(value).Go)
+		if name == "" {
+			return "Empty"
+		}
+		return name
+	default:
+		s := strings.ToUpper(string(v.JSON))
+		s = strings.ReplaceAll(s, "-", "Minus")
+		s = strings.ReplaceAll(s, ".", "Point")
+		s = strings.ReplaceAll(s, "+", "")
+		return s
+	}
+}
+
+// enumValueKind describes the type of an enum value, which is added to the
+// names of constants that would otherwise be the same.
+func enumValueKind(v enumValue) string {
+	switch v.Value.(type) {
+	case nil:
+		return "Null"
+	case bool:
+		return "Bool"
+	case string:
+		return "String"
+	default:
+		return "Number"
+	}
+}
+
+// generateEnumType generates a type with a constant for each enum value. Enums
+// of only strings or only integers use a string or int type. Enums of values
+// of different types, such as `enum [ false "auto" ]`, use a string type that
+// holds the JSON of the value, with methods to marshal it as such.
+func (g *generatingFile) generateEnumType(name optionName, path modulePath, option nixmodule.EnumOption, _ ...optionOpt) string {
+	values := make([]enumValue, len(option.Enum))
+	for i, raw := range option.Enum {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			panic(fmt.Sprintf("invalid enum value %s: %v", raw, err))
+		}
+		// Re-encode the value the way the generated code does, so that the
+		// constants can be compared to it.
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("invalid enum value %s: %v", raw, err))
+		}
+		values[i] = enumValue{Raw: bytes.TrimSpace(raw), JSON: b, Value: v}
+	}
+
+	kind := enumKind(values)
+
+	// Manually construct this code since `gen` doesn't have either `type` or
+	// `const` statements supported.
+	var s strings.Builder
+
+	switch kind {
+	case "string", "int":
+		fmt.Fprintf(&s, "type %s %s\n", name.Go, kind)
+	default:
+		fmt.Fprintf(&s, "type %s string\n", name.Go)
+	}
+	fmt.Fprintln(&s)
+
+	used := make(map[string]bool, len(values))
+	constNames := make([]string, len(values))
+
+	fmt.Fprintln(&s, "const (")
+	for i, value := range values {
+		constName := name.Go + enumValueName(value)
+		if used[constName] {
+			constName += enumValueKind(value)
+		}
+		used[constName] = true
+		constNames[i] = constName
+
+		switch kind {
+		case "string":
+			fmt.Fprintf(&s, "%s %s = %q\n", constName, name.Go, value.Value)
+		case "int":
+			fmt.Fprintf(&s, "%s %s = %s\n", constName, name.Go, value.Raw)
+		default:
+			fmt.Fprintf(&s, "%s %s = %s\n", constName, name.Go, strconv.Quote(string(value.JSON)))
+		}
+	}
+	fmt.Fprintln(&s, ")")
+
+	stmts := []gen.Statement{
+		gen.NewCommentf(" %s is the enum type for %s.", name.Go, path.GoDocForNixPath()),
+	}
+	if kind == "" {
+		stmts = append(stmts,
+			gen.NewComment(" Its values are of different types, so each value holds its JSON encoding."))
+	}
+	stmts = append(stmts, gen.NewRawStatement(s.String()))
+	if kind == "" {
+		stmts = append(stmts, g.generateMixedEnumMethods(name, constNames)...)
+	}
+
+	g.append(stmts...)
+
+	return name.Go
+}
+
+// generateMixedEnumMethods generates the JSON methods of an enum whose values
+// hold their JSON encoding.
+func (g *generatingFile) generateMixedEnumMethods(name optionName, constNames []string) []gen.Statement {
+	g.addImport("encoding/json")
+	g.addImport("fmt")
+
+	recv := strcases.FirstLetter(name.Go)
+
+	return []gen.Statement{
+		gen.NewCommentf(" MarshalJSON implements the [json.Marshaler] interface for [%s].", name.Go),
+		gen.NewCommentf(" The zero value is marshaled as null."),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, name.Go),
+			gen.NewFuncSignature("MarshalJSON").
+				ReturnTypes("[]byte", "error"),
+			gen.NewIf(fmt.Sprintf("%s == \"\"", recv),
+				gen.NewReturnStatement(`[]byte("null"), nil`),
+			),
+			gen.NewReturnStatement(fmt.Sprintf("[]byte(%s), nil", recv)),
+		),
+		gen.NewNewline(),
+		gen.NewCommentf(" UnmarshalJSON implements the [json.Unmarshaler] interface for [%s].", name.Go),
+		gen.NewCommentf(" It returns an error if the value isn't one of the enum values."),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, "*"+name.Go),
+			gen.NewFuncSignature("UnmarshalJSON").
+				Parameters(gen.NewFuncParameter("data", "[]byte")).
+				ReturnTypes("error"),
+			gen.NewRawStatement("var v any"),
+			gen.NewIf("err := json.Unmarshal(data, &v); err != nil",
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewRawStatement("b, err := json.Marshal(v)"),
+			gen.NewIf("err != nil",
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewRawStatementf("switch v := %s(b); v {", name.Go),
+			gen.NewRawStatementf("case %s:", strings.Join(constNames, ", ")),
+			gen.NewRawStatementf("*%s = v", recv),
+			gen.NewReturnStatement("nil"),
+			gen.NewRawStatement("}"),
+			gen.NewReturnStatement(fmt.Sprintf("fmt.Errorf(%q, data)", "%s is not a valid "+name.Go)),
+		),
+	}
+}
@@ -0,0 +1,78 @@
+package nixmod2go
+
+import (
+	"fmt"
+
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// FilterMode decides what to do with the options matched by a filter in
+// [Opts].
+type FilterMode string
+
+const (
+	// FilterKeep generates the options like any other. It's the default.
+	FilterKeep FilterMode = "keep"
+	// FilterSkip leaves the options out of the generated structs.
+	FilterSkip FilterMode = "skip"
+	// FilterSeparate moves the options of each struct into a separate struct,
+	// which is embedded into it so that they're still marshaled along with the
+	// other options.
+	FilterSeparate FilterMode = "separate"
+)
+
+// filterMode returns what to do with an option, given its documentation.
+// Skipping takes precedence over separating if several filters match.
+func (o Opts) filterMode(doc nixmodule.OptionDoc) FilterMode {
+	var modes []FilterMode
+	if doc.Internal {
+		modes = append(modes, o.Internal)
+	}
+	if doc.Visible == nixmodule.VisibilityHidden {
+		modes = append(modes, o.Invisible)
+	}
+	if doc.ReadOnly {
+		modes = append(modes, o.ReadOnly)
+	}
+
+	mode := FilterKeep
+	for _, m := range modes {
+		switch m {
+		case FilterSkip:
+			return FilterSkip
+		case FilterSeparate:
+			mode = FilterSeparate
+		}
+	}
+	return mode
+}
+
+// hiddenStructName returns the name of the struct that the filtered options of
+// the struct name are moved into. It's suffixed with Hidden, and numbered if
+// that's already the Go name of an option, so that it can't collide with the
+// types generated for options.
+func (g *generatingFile) hiddenStructName(name optionName) string {
+	hidden := name.Go + "Hidden"
+	for i := 2; ; i++ {
+		if _, taken := g.optionNames[hidden]; !taken {
+			break
+		}
+		hidden = fmt.Sprintf("%sHidden%d", name.Go, i)
+	}
+	g.optionNames[hidden] = struct{}{}
+	return hidden
+}
+
+// optionGoNames returns the set of the Go names of all options and namespaces
+// within module.
+func optionGoNames(module nixmodule.Module) map[string]struct{} {
+	names := make(map[string]struct{})
+	for path := range module.All() {
+		switch last := path[len(path)-1]; last {
+		case "*", "<name>":
+		default:
+			names[parseName(last).Go] = struct{}{}
+		}
+	}
+	return names
+}
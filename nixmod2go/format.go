@@ -0,0 +1,39 @@
+package nixmod2go
+
+import (
+	_ "embed"
+
+	"libdb.so/nixmod2go/nixmodule"
+
+	gen "github.com/moznion/gowrtr/generator"
+)
+
+//go:embed format_value.go.tmpl
+var formatValueCode string
+
+// generateFormatType returns the Go type of a pkgs.formats option. All formats
+// share the FormatValue type, which is generated once per file.
+func (g *generatingFile) generateFormatType(option nixmodule.FormatOption) string {
+	g.generateFormatValue()
+
+	switch option.Format {
+	case nixmodule.FormatINI:
+		return "map[string]map[string]FormatValue"
+	case nixmodule.FormatKeyValue:
+		return "map[string]FormatValue"
+	default:
+		return "FormatValue"
+	}
+}
+
+func (g *generatingFile) generateFormatValue() {
+	if g.formatValue {
+		return
+	}
+	g.formatValue = true
+
+	g.addImport("bytes")
+	g.addImport("encoding/json")
+	g.addImport("errors")
+	g.append(gen.NewRawStatement(formatValueCode))
+}
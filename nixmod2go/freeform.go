@@ -0,0 +1,127 @@
+package nixmod2go
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/gir/girgen/strcases"
+	"libdb.so/nixmod2go/nixmodule"
+
+	gen "github.com/moznion/gowrtr/generator"
+)
+
+// generateFreeformType returns the Go type of the undeclared attributes of a
+// freeform submodule. Freeform types are usually attrsOf types, in which case
+// their element type is used. The freeform types of pkgs.formats are
+// attribute sets of FormatValues. Other freeform types are kept as raw JSON
+// so that they round-trip losslessly.
+func (g *generatingFile) generateFreeformType(name optionName, path modulePath, freeform nixmodule.Option) string {
+	switch freeform := freeform.(type) {
+	case nixmodule.AttrsOfOption:
+		// Only the element type is generated, but the attrsOf type still
+		// counts as an ancestor of it.
+		g.ancestors = append(g.ancestors, "")
+		defer func() { g.ancestors = g.ancestors[:len(g.ancestors)-1] }()
+		return g.generateOptionType(name.concat("-extra"), path.Add(optionName{"<name>", "Extra"}), freeform.AtrrsOf)
+	case nixmodule.FormatOption:
+		g.generateFormatValue()
+		if freeform.Format == nixmodule.FormatINI {
+			// Undeclared attributes are sections.
+			return "map[string]FormatValue"
+		}
+		return "FormatValue"
+	}
+	g.addImport("encoding/json")
+	return "json.RawMessage"
+}
+
+// freeformField returns the name of the field that keeps undeclared
+// attributes. It's Extra unless the module declares an option with that name.
+func freeformField(module sortedModule) string {
+	field := "Extra"
+	for _, item := range module {
+		if parseName(item.Name).Go == field {
+			field = "FreeformExtra"
+		}
+	}
+	return field
+}
+
+// generateFreeformMethods generates the JSON methods of a struct that keeps
+// its undeclared attributes in the given field.
+func (g *generatingFile) generateFreeformMethods(name optionName, module sortedModule, field, extraType string) []gen.Statement {
+	g.addImport("encoding/json")
+	g.addImport("fmt")
+
+	recv := strcases.FirstLetter(name.Go)
+
+	declared := make([]string, len(module))
+	for i, item := range module {
+		declared[i] = fmt.Sprintf("%q", item.Name)
+	}
+
+	return []gen.Statement{
+		gen.NewNewline(),
+		gen.NewCommentf(" UnmarshalJSON implements the [json.Unmarshaler] interface for [%s].", name.Go),
+		gen.NewCommentf(" Undeclared attributes are kept in %s.", field),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, "*"+name.Go),
+			gen.NewFuncSignature("UnmarshalJSON").
+				Parameters(gen.NewFuncParameter("data", "[]byte")).
+				ReturnTypes("error"),
+			gen.NewRawStatementf("type declared %s", name.Go),
+			gen.NewIf(fmt.Sprintf("err := json.Unmarshal(data, (*declared)(%s)); err != nil", recv),
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewNewline(),
+			gen.NewRawStatement("var attrs map[string]json.RawMessage"),
+			gen.NewIf("err := json.Unmarshal(data, &attrs); err != nil",
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewRawStatementf("for _, k := range []string{%s} {\ndelete(attrs, k)\n}", strings.Join(declared, ", ")),
+			gen.NewNewline(),
+			gen.NewRawStatementf("%s.%s = nil", recv, field),
+			gen.NewRawStatementf(`for k, v := range attrs {
+				var value %[3]s
+				if err := json.Unmarshal(v, &value); err != nil {
+					return fmt.Errorf("%%s: %%w", k, err)
+				}
+				if %[1]s.%[2]s == nil {
+					%[1]s.%[2]s = make(map[string]%[3]s, len(attrs))
+				}
+				%[1]s.%[2]s[k] = value
+			}`, recv, field, extraType),
+			gen.NewReturnStatement("nil"),
+		),
+		gen.NewNewline(),
+		gen.NewCommentf(" MarshalJSON implements the [json.Marshaler] interface for [%s].", name.Go),
+		gen.NewCommentf(" Attributes in %s are marshaled along with the declared ones.", field),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, name.Go),
+			gen.NewFuncSignature("MarshalJSON").
+				ReturnTypes("[]byte", "error"),
+			gen.NewRawStatementf("type declared %s", name.Go),
+			gen.NewRawStatementf("data, err := json.Marshal(declared(%s))", recv),
+			gen.NewIf(fmt.Sprintf("err != nil || len(%s.%s) == 0", recv, field),
+				gen.NewReturnStatement("data, err"),
+			),
+			gen.NewNewline(),
+			gen.NewRawStatement("var attrs map[string]json.RawMessage"),
+			gen.NewIf("err := json.Unmarshal(data, &attrs); err != nil",
+				gen.NewReturnStatement("nil, err"),
+			),
+			gen.NewRawStatementf(`for k, v := range %s.%s {
+				if _, ok := attrs[k]; ok {
+					// Declared attributes take precedence.
+					continue
+				}
+				b, err := json.Marshal(v)
+				if err != nil {
+					return nil, fmt.Errorf("%%s: %%w", k, err)
+				}
+				attrs[k] = b
+			}`, recv, field),
+			gen.NewReturnStatement("json.Marshal(attrs)"),
+		),
+	}
+}
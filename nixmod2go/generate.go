@@ -6,6 +6,7 @@ import (
 	"go/doc/comment"
 	"log/slog"
 	"maps"
+	"path/filepath"
 	"slices"
 	"strings"
 
@@ -21,6 +22,23 @@ type Opts struct {
 	// RootName is the name of the root struct type.
 	// By default, it's "Config".
 	RootName string
+	// LocationRoot is the directory that option locations in generated
+	// comments are made relative to. Locations outside of it are kept
+	// absolute. By default, locations are always absolute.
+	LocationRoot string
+	// AcceptCoerced makes the types of coercedTo options accept values of
+	// both the original and the final type, like the Nix module system does
+	// when config is written. By default, only the final type is accepted,
+	// which is what evaluated config always contains.
+	AcceptCoerced bool
+	// Internal, Invisible and ReadOnly decide what to do with options that are
+	// internal, hidden from the documentation (visible = false) or read-only.
+	// By default, they're generated like any other option. Options that are
+	// only partially hidden, with visible set to "shallow" or "transparent",
+	// aren't invisible.
+	Internal  FilterMode
+	Invisible FilterMode
+	ReadOnly  FilterMode
 }
 
 // Generate generates Go struct definitions from Nix modules.
@@ -35,8 +53,13 @@ func Generate(module nixmodule.Module, packageName string, opts Opts) (string, e
 	)
 
 	f := generatingFile{
-		imports: make(map[string]struct{}),
-		slog:    slog,
+		imports:     make(map[string]struct{}),
+		validated:   make(map[string]struct{}),
+		eitherTypes: make(map[string]struct{}),
+		optionNames: optionGoNames(module),
+		typeNames:   make(map[string]struct{}),
+		opts:        opts,
+		slog:        slog,
 	}
 
 	slog.Debug("generating Go struct definitions from Nix modules")
@@ -60,7 +83,24 @@ func Generate(module nixmodule.Module, packageName string, opts Opts) (string, e
 type generatingFile struct {
 	statements []gen.Statement
 	imports    map[string]struct{}
-	slog       *slog.Logger
+	// validated is the set of generated types that have a Validate method.
+	validated map[string]struct{}
+	// eitherTypes is the set of generated JSON wrappers of either types.
+	eitherTypes map[string]struct{}
+	// optionNames is the set of the Go names of all options, which the types
+	// generated for them may be named after.
+	optionNames map[string]struct{}
+	// typeNames is the set of the names of the types generated for options
+	// by [generatingFile.typeName].
+	typeNames map[string]struct{}
+	// formatValue is true if FormatValue has been generated.
+	formatValue bool
+	// ancestors are the names of the types that the option being generated
+	// is nested within, innermost last. Types that no RefOption refers to
+	// have empty names.
+	ancestors []string
+	opts      Opts
+	slog      *slog.Logger
 }
 
 func (g *generatingFile) generate(root sortedModule, rootName string) {
@@ -70,6 +110,7 @@ func (g *generatingFile) generate(root sortedModule, rootName string) {
 
 type optionOpts struct {
 	forceInline bool
+	freeform    nixmodule.Option
 }
 
 type optionOpt func(*optionOpts)
@@ -96,6 +137,12 @@ func optionForceInline(o *optionOpts) {
 	o.forceInline = true
 }
 
+// optionFreeform makes the generated struct keep undeclared attributes of the
+// given freeform type.
+func optionFreeform(freeform nixmodule.Option) optionOpt {
+	return func(o *optionOpts) { o.freeform = freeform }
+}
+
 func (g *generatingFile) generateItemType(path modulePath, item optionItem, opts ...optionOpt) string {
 	g.slog.Debug(
 		"generating item type",
@@ -128,20 +175,84 @@ func (g *generatingFile) generateModuleType(name optionName, path modulePath, mo
 	// Manually generate a struct here. `gen` doesn't support adding comments
 	// before each struct field for some reason?
 	var s strings.Builder
+	var validations []string
+
+	// separated are the fields moved into a separate struct by the filters.
+	var separated strings.Builder
+	var separatedName string
 
 	fmt.Fprintf(&s, "struct {\n")
 	for _, item := range module {
+		fields := &s
+		if item.Option != nil {
+			switch g.opts.filterMode((*item.Option).Doc()) {
+			case FilterSkip:
+				g.slog.Debug(
+					"skipping filtered option",
+					"path", path.Add(parseName(item.Name)))
+				continue
+			case FilterSeparate:
+				if o.forceInline {
+					g.slog.Warn(
+						"inline structs cannot separate filtered options, keeping them",
+						"path", path.Add(parseName(item.Name)))
+				} else {
+					fields = &separated
+				}
+			}
+		}
+
 		valueName := parseName(item.Name)
 		valueType := g.generateItemType(path.Add(valueName), item)
 
-		if item.Option != nil && (*item.Option).Doc().Description != "" {
-			if cmt := docComment((*item.Option).Doc(), valueName.Go, 1); cmt != "" {
-				fmt.Fprint(&s, cmt)
+		if item.Option != nil && isRequired((*item.Option).Doc()) {
+			validations = append(validations, g.requiredCode(
+				valueType,
+				strcases.FirstLetter(name.Go)+"."+valueName.Go,
+				item.Name))
+		}
+
+		validations = append(validations, g.validateCode(
+			valueType,
+			strcases.FirstLetter(name.Go)+"."+valueName.Go,
+			strings.ReplaceAll(item.Name, "%", "%%"), nil, 0))
+
+		if item.Option != nil {
+			if cmt := g.docComment((*item.Option).Doc(), valueName.Go, 1); cmt != "" {
+				fmt.Fprint(fields, cmt)
 			}
 		}
 
-		fmt.Fprintf(&s, "\t%s %s `json:%q`\n", valueName.Go, valueType, item.Name)
+		fmt.Fprintf(fields, "\t%s %s `json:%q`\n", valueName.Go, valueType, item.Name)
+	}
+
+	if separated.Len() > 0 {
+		separatedName = g.hiddenStructName(name)
+		fmt.Fprintf(&s, "\t// %s contains the internal, invisible or read-only options of\n", separatedName)
+		fmt.Fprintf(&s, "\t// %s.\n", path.GoDocForNixPath())
+		fmt.Fprintf(&s, "\t%s\n", separatedName)
+	}
+
+	var freeformMethods []gen.Statement
+	if o.freeform != nil {
+		if o.forceInline {
+			g.slog.Warn(
+				"inline structs cannot keep undeclared attributes of freeform submodules",
+				"path", path)
+		} else {
+			extraField := freeformField(module)
+			extraType := g.generateFreeformType(name, path, o.freeform)
+			fmt.Fprintf(&s, "\t// %s contains the values of undeclared attributes, which are\n", extraField)
+			fmt.Fprintf(&s, "\t// allowed by the freeform type of %s.\n", path.GoDocForNixPath())
+			fmt.Fprintf(&s, "\t%s map[string]%s `json:\"-\"`\n", extraField, extraType)
+			freeformMethods = g.generateFreeformMethods(name, module, extraField, extraType)
+			validations = append(validations, g.validateCode(
+				"map[string]"+extraType,
+				strcases.FirstLetter(name.Go)+"."+extraField,
+				"", nil, 0))
+		}
 	}
+
 	fmt.Fprint(&s, "}")
 
 	if o.forceInline {
@@ -150,10 +261,25 @@ func (g *generatingFile) generateModuleType(name optionName, path modulePath, mo
 		// Choose to prepend the struct. This is because [generateItemType] will
 		// recursively generate its own type before we can append our struct in, so
 		// it'll naturally appear at the end by the time we're here.
-		g.prepend(
-			gen.NewCommentf(" %s is the struct type for %s.", name.Go, path.GoDocForNixPath()),
-			gen.NewRawStatementf("type %s %s\n", name.Go, s.String()),
-		)
+		var separatedType []gen.Statement
+		if separated.Len() > 0 {
+			separatedType = []gen.Statement{
+				gen.NewNewline(),
+				gen.NewCommentf(" %s contains the options of [%s] that are moved out of it by", separatedName, name.Go),
+				gen.NewCommentf(" the filters, which are internal, invisible or read-only."),
+				gen.NewRawStatementf("type %s struct {\n%s}\n", separatedName, separated.String()),
+			}
+		}
+
+		g.prepend(slices.Concat(
+			[]gen.Statement{
+				gen.NewCommentf(" %s is the struct type for %s.", name.Go, path.GoDocForNixPath()),
+				gen.NewRawStatementf("type %s %s\n", name.Go, s.String()),
+			},
+			separatedType,
+			freeformMethods,
+			g.generateValidateMethod(name, validations),
+		)...)
 
 		return name.Go
 	}
@@ -166,15 +292,42 @@ func (g *generatingFile) generateOptionType(name optionName, path modulePath, op
 		"name", name.Nix,
 		"option", option.Type())
 
+	// References only name one of the ancestors, so they aren't ancestors
+	// themselves.
+	if ref, ok := option.(nixmodule.RefOption); ok {
+		return g.generateRefType(path, ref)
+	}
+
+	if isReferenced(option) {
+		return g.generateRecursiveType(name, path, option, opts...)
+	}
+
+	g.ancestors = append(g.ancestors, "")
+	defer func() { g.ancestors = g.ancestors[:len(g.ancestors)-1] }()
+
+	return g.generateOptionTypeOf(name, path, option, opts...)
+}
+
+// generateOptionTypeOf generates the type of the option without keeping track
+// of its ancestors. Use generateOptionType instead.
+func (g *generatingFile) generateOptionTypeOf(name optionName, path modulePath, option nixmodule.Option, opts ...optionOpt) string {
 	switch option := option.(type) {
 	case nixmodule.StrOption:
 		return "string"
 	case nixmodule.IntOption:
 		return "int"
 	case nixmodule.IntBetweenOption:
-		return "int"
+		if len(option.Warnings) > 0 {
+			// The bounds are unknown.
+			return "int"
+		}
+		return g.generateRangeType(name, path, intRange(option.Min, option.Max))
 	case nixmodule.PositiveIntOption:
-		return "uint"
+		return g.generateRangeType(name, path, rangeCheck{
+			GoType: "uint",
+			Conds:  []string{"v == 0"},
+			Desc:   "positive",
+		})
 	case nixmodule.SignedInt8Option:
 		return "int8"
 	case nixmodule.SignedInt16Option:
@@ -197,17 +350,38 @@ func (g *generatingFile) generateOptionType(name optionName, path modulePath, op
 		return "bool"
 	case nixmodule.FloatOption:
 		return "float64"
+	case nixmodule.NumberBetweenOption:
+		if len(option.Warnings) > 0 {
+			// The bounds are unknown.
+			return "float64"
+		}
+		return g.generateRangeType(name, path, floatRange(option.Min, option.Max))
+	case nixmodule.NumberPositiveOption:
+		return g.generateRangeType(name, path, rangeCheck{
+			GoType: "float64",
+			Conds:  []string{"v <= 0"},
+			Desc:   "positive",
+		})
+	case nixmodule.NumberNonnegativeOption:
+		return g.generateRangeType(name, path, rangeCheck{
+			GoType: "float64",
+			Conds:  []string{"v < 0"},
+			Desc:   "nonnegative",
+		})
 	case nixmodule.AttrsOption:
 		return "map[string]any"
 	case nixmodule.AnythingOption:
 		return "any"
 	case nixmodule.UnspecifiedOption:
-		g.addImport("encoding/json")
-		return "json.RawMessage"
+		return g.generateUnspecifiedType(name, path, option, opts...)
+	case nixmodule.UntypedOption:
+		return g.generateUntypedType(path, option)
 	case nixmodule.EnumOption:
 		return g.generateEnumType(name, path, option, opts...)
 	case nixmodule.SeparatedString:
 		return "string" // TODO: generate type that has .Split()
+	case nixmodule.StrMatchingOption:
+		return g.generateStrMatchingType(name, path, option)
 	case nixmodule.UniqueOption:
 		return g.generateOptionType(name, path, option.Unique, opts...)
 	case nixmodule.EitherOption:
@@ -223,35 +397,30 @@ func (g *generatingFile) generateOptionType(name optionName, path modulePath, op
 	case nixmodule.AttrsOfOption:
 		return "map[string]" + g.generateOptionType(name, path, option.AtrrsOf, opts...)
 	case nixmodule.SubmoduleOption:
+		if option.Freeform != nil {
+			opts = addOptions(opts, optionFreeform(option.Freeform))
+		}
 		return g.generateModuleType(name, path, sortModule(option.Submodule), opts...)
+	case nixmodule.FormatOption:
+		return g.generateFormatType(option)
+	case nixmodule.DeferredModuleOption:
+		return g.generateOpaqueType(name, path, "Nix modules", opts...)
+	case nixmodule.FunctionToOption:
+		return g.generateOpaqueType(name, path,
+			fmt.Sprintf("Nix functions that return values of type `%s`", option.FunctionTo.Type()), opts...)
+	case nixmodule.CoercedToOption:
+		if g.opts.AcceptCoerced {
+			return g.generateEitherType(name, path, nixmodule.EitherOption{
+				OptionDoc: option.OptionDoc,
+				Either:    []nixmodule.Option{option.To, option.From},
+			}, opts...)
+		}
+		return g.generateOptionType(name, path, option.To, opts...)
 	default:
 		panic("unreachable")
 	}
 }
 
-func (g *generatingFile) generateEnumType(name optionName, path modulePath, option nixmodule.EnumOption, _ ...optionOpt) string {
-	// Manually construct this code since `gen` doesn't have either `type` or
-	// `const` statements supported.
-	var s strings.Builder
-
-	fmt.Fprintf(&s, "type %s string\n", name.Go)
-	fmt.Fprintln(&s)
-
-	fmt.Fprintln(&s, "const (")
-	for _, value := range option.Enum {
-		valueName := parseName(value)
-		fmt.Fprintf(&s, "%s %s = %q\n", name.Go+valueName.Go, name.Go, value)
-	}
-	fmt.Fprintln(&s, ")")
-
-	g.append(
-		gen.NewCommentf(" %s is the enum type for %s.", name.Go, path.GoDocForNixPath()),
-		gen.NewRawStatement(s.String()),
-	)
-
-	return name.Go
-}
-
 func (g *generatingFile) generateEitherType(name optionName, path modulePath, option nixmodule.EitherOption, opts ...optionOpt) string {
 	iface := []gen.Statement{
 		gen.NewCommentf(" %s describes the `either` type for %s.", name.Go, path.GoDocForNixPath()),
@@ -268,6 +437,9 @@ func (g *generatingFile) generateEitherType(name optionName, path modulePath, op
 	for i, option := range option.Either {
 		optionName := parseName(option.Type())
 		optionName.Go = name.Go + optionName.Go
+		// The named types generated for the option, such as ranges, must
+		// not take the name of this type.
+		g.typeNames[optionName.Go] = struct{}{}
 
 		optionType := g.generateOptionType(optionName, path, option,
 			// Force the generated type to be inline.
@@ -365,6 +537,7 @@ func (g *generatingFile) generateEitherType(name optionName, path modulePath, op
 
 	g.append(unmarshalFunc)
 
+	g.eitherTypes[name.Go+"JSON"] = struct{}{}
 	return name.Go + "JSON"
 }
 
@@ -385,12 +558,62 @@ func (g *generatingFile) importStatement() gen.Statement {
 	return gen.NewImport(slices.Collect(maps.Keys(g.imports))...)
 }
 
-func docComment(doc nixmodule.OptionDoc, what string, indentLvl int) string {
-	if doc.Description == "" {
+func (g *generatingFile) docComment(doc nixmodule.OptionDoc, what string, indentLvl int) string {
+	var paragraphs []string
+	if doc.Description != "" {
+		paragraphs = append(paragraphs, cmt.FixGrammar(what, doc.Description))
+	}
+	if isRequired(doc) {
+		paragraphs = append(paragraphs, "Required: it has no default value, so it must be set.")
+	}
+	if doc.DefaultText.IsZero() {
+		paragraphs = appendLiteral(paragraphs, "Default", doc.Default)
+	} else {
+		paragraphs = appendLiteral(paragraphs, "Default", doc.DefaultText)
+	}
+	paragraphs = appendLiteral(paragraphs, "Example", doc.Example)
+	if doc.Location.File != "" {
+		paragraphs = append(paragraphs, fmt.Sprintf("Declared at %s.", g.location(doc.Location)))
+	}
+	if len(paragraphs) == 0 {
 		return ""
 	}
-	s := cmt.FixGrammar(what, doc.Description)
-	return fmtComment(s, indentLvl)
+	return fmtComment(strings.Join(paragraphs, "\n\n"), indentLvl)
+}
+
+// appendLiteral appends the paragraphs that document a literal, such as the
+// default value, if it's set. Nix expressions are shown as code blocks, and
+// values that couldn't be dumped are left out.
+func appendLiteral(paragraphs []string, label string, lit nixmodule.Literal) []string {
+	switch lit.Kind {
+	case nixmodule.LiteralExpression:
+		code := strings.TrimSpace(lit.Text)
+		return append(paragraphs,
+			label+":",
+			"\t"+strings.ReplaceAll(code, "\n", "\n\t"))
+	case nixmodule.LiteralMarkdown:
+		return append(paragraphs, label+": "+strings.TrimSpace(lit.Text))
+	case nixmodule.LiteralValue:
+		return append(paragraphs, label+": "+string(lit.Value))
+	default:
+		return paragraphs
+	}
+}
+
+// location formats loc as `file:line`, relative to [Opts.LocationRoot] if
+// possible.
+func (g *generatingFile) location(loc nixmodule.Location) string {
+	file := loc.File
+	if g.opts.LocationRoot != "" {
+		rel, err := filepath.Rel(g.opts.LocationRoot, file)
+		if err == nil && filepath.IsLocal(rel) {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	if loc.Line == 0 {
+		return file
+	}
+	return fmt.Sprintf("%s:%d", file, loc.Line)
 }
 
 func fmtComment(s string, indentLvl int) string {
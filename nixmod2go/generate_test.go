@@ -0,0 +1,144 @@
+package nixmod2go
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// siblings returns a module of two submodules a and b that both declare the
+// option name.
+func siblings(name string, option nixmodule.Option) nixmodule.Module {
+	return nixmodule.Module{
+		"a": nixmodule.SubmoduleOption{Submodule: nixmodule.Module{name: option}},
+		"b": nixmodule.SubmoduleOption{Submodule: nixmodule.Module{name: option}},
+	}
+}
+
+func TestGenerateTypeNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		module nixmodule.Module
+		want   []string
+	}{
+		{
+			name:   "same-named ranges",
+			module: siblings("workers", nixmodule.PositiveIntOption{}),
+			want:   []string{"A", "B", "BWorkers", "Config", "Workers"},
+		},
+		{
+			name: "range within either",
+			module: nixmodule.Module{
+				"level": nixmodule.EitherOption{Either: []nixmodule.Option{
+					nixmodule.IntBetweenOption{Min: 1, Max: 5},
+					nixmodule.StrOption{},
+				}},
+			},
+			want: []string{"Config", "Level", "LevelIntBetween", "LevelIntBetween2", "LevelJSON", "LevelStr"},
+		},
+		{
+			name:   "same-named patterns",
+			module: siblings("host", nixmodule.StrMatchingOption{Pattern: "[a-z]+"}),
+			want:   []string{"A", "B", "BHost", "Config", "Host"},
+		},
+		{
+			name:   "same-named opaque options",
+			module: siblings("imports", nixmodule.DeferredModuleOption{}),
+			want:   []string{"A", "B", "BImports", "Config", "Imports"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := Generate(test.module, "config", Opts{})
+			if err != nil {
+				t.Fatal("cannot generate:", err)
+			}
+
+			got := checkGenerated(t, code)
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(test.want))
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected types (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateStrMatchingUnsupported(t *testing.T) {
+	module := nixmodule.Module{
+		"twice": nixmodule.StrMatchingOption{Pattern: `(a)\1`},
+	}
+
+	code, err := Generate(module, "config", Opts{})
+	if err != nil {
+		t.Fatal("cannot generate:", err)
+	}
+	checkGenerated(t, code)
+
+	const warning = "// Warning: Go cannot compile the pattern, so it isn't enforced.\ntype Twice string\n"
+	if !strings.Contains(code, warning) {
+		t.Errorf("generated code doesn't warn about the pattern:\n%s", code)
+	}
+}
+
+func TestGenerateRequired(t *testing.T) {
+	module := nixmodule.Module{
+		"hosts": nixmodule.ListOfOption{ListOf: nixmodule.StrOption{}},
+		"paths": nixmodule.ListOfOption{
+			OptionDoc: nixmodule.OptionDoc{ReadOnly: true},
+			ListOf:    nixmodule.StrOption{},
+		},
+		"state": nixmodule.ListOfOption{
+			OptionDoc: nixmodule.OptionDoc{Internal: true},
+			ListOf:    nixmodule.StrOption{},
+		},
+	}
+
+	code, err := Generate(module, "config", Opts{})
+	if err != nil {
+		t.Fatal("cannot generate:", err)
+	}
+	checkGenerated(t, code)
+
+	for name, required := range map[string]bool{"hosts": true, "paths": false, "state": false} {
+		if got := strings.Contains(code, `"`+name+`: required but not set"`); got != required {
+			t.Errorf("%s: required is %t, want %t:\n%s", name, got, required, code)
+		}
+	}
+}
+
+// checkGenerated type-checks the generated code and returns the names of the
+// types it declares.
+func checkGenerated(t *testing.T, code string) []string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config.go", code, 0)
+	if err != nil {
+		t.Fatalf("cannot parse generated code: %v\n%s", err, code)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("config", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code doesn't compile: %v\n%s", err, code)
+	}
+
+	var names []string
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+			for _, spec := range decl.Specs {
+				names = append(names, spec.(*ast.TypeSpec).Name.Name)
+			}
+		}
+	}
+	return names
+}
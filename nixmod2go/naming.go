@@ -1,6 +1,7 @@
 package nixmod2go
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/diamondburned/gotk4/gir/girgen/strcases"
@@ -38,3 +39,30 @@ func (n optionName) unexport() optionName {
 	n.Go = strcases.UnexportPascal(n.Go)
 	return n
 }
+
+// typeName returns the name of a type generated for the option at path, such
+// as a range or pattern type. It's the Go name of the option unless another
+// such type already has that name, e.g. for options of the same name within
+// different submodules, in which case the names of the option's parents are
+// prepended, innermost first, until it's unique.
+func (g *generatingFile) typeName(name optionName, path modulePath) string {
+	taken := func(typeName string) bool {
+		_, taken := g.typeNames[typeName]
+		return taken
+	}
+
+	typeName := name.Go
+	parents := path[:max(len(path)-1, 0)]
+	for i := len(parents) - 1; i >= 0 && taken(typeName); i-- {
+		typeName = parents[i].Go + typeName
+	}
+
+	// Even the full path is taken, which is possible within either types.
+	qualified := typeName
+	for i := 2; taken(typeName); i++ {
+		typeName = fmt.Sprintf("%s%d", qualified, i)
+	}
+
+	g.typeNames[typeName] = struct{}{}
+	return typeName
+}
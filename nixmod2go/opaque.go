@@ -0,0 +1,38 @@
+package nixmod2go
+
+import (
+	"fmt"
+
+	gen "github.com/moznion/gowrtr/generator"
+)
+
+// generateOpaqueType generates the type of an option whose values can't be
+// represented in Go, such as Nix functions. The type is an alias of
+// json.RawMessage, so that values that happen to be convertible to JSON are
+// still kept. It's named by [generatingFile.typeName]. what describes the
+// values, such as "Nix modules".
+func (g *generatingFile) generateOpaqueType(name optionName, path modulePath, what string, opts ...optionOpt) string {
+	g.slog.Warn(
+		"option values cannot be represented in Go, using raw JSON instead",
+		"path", path,
+		"values", what)
+
+	g.addImport("encoding/json")
+	if buildOptionOpts(opts).forceInline {
+		return "json.RawMessage"
+	}
+
+	typeName := g.typeName(name, path)
+	doc := fmt.Sprintf(
+		"%s is the type for %s.\n\n"+
+			"Warning: its values are %s, which cannot be represented in Go. "+
+			"They are kept as raw JSON, which is only possible if Nix can "+
+			"convert them to JSON at all.",
+		typeName, path.GoDocForNixPath(), what)
+
+	// fmtComment ends with a newline, so the type is put within the same
+	// statement to keep the comment attached to it.
+	g.append(gen.NewRawStatement(fmtComment(doc, 0) + "type " + typeName + " = json.RawMessage"))
+
+	return typeName
+}
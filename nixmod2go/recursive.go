@@ -0,0 +1,128 @@
+package nixmod2go
+
+import (
+	"maps"
+	"slices"
+
+	"libdb.so/nixmod2go/nixmodule"
+
+	gen "github.com/moznion/gowrtr/generator"
+)
+
+// nestedOptions returns the options directly nested within the given option,
+// which are one level below it for [nixmodule.RefOption]. The options of
+// submodules are all one level below, no matter how deeply they're nested
+// within plain attribute sets.
+func nestedOptions(option nixmodule.Option) []nixmodule.Option {
+	switch option := option.(type) {
+	case nixmodule.Module:
+		var nested []nixmodule.Option
+		for _, o := range option {
+			if module, ok := o.(nixmodule.Module); ok {
+				nested = append(nested, nestedOptions(module)...)
+			} else {
+				nested = append(nested, o)
+			}
+		}
+		return nested
+	case nixmodule.SubmoduleOption:
+		nested := nestedOptions(option.Submodule)
+		if option.Freeform != nil {
+			nested = append(nested, option.Freeform)
+		}
+		return nested
+	case nixmodule.UniqueOption:
+		return []nixmodule.Option{option.Unique}
+	case nixmodule.NullOrOption:
+		return []nixmodule.Option{option.NullOr}
+	case nixmodule.ListOfOption:
+		return []nixmodule.Option{option.ListOf}
+	case nixmodule.AttrsOfOption:
+		return []nixmodule.Option{option.AtrrsOf}
+	case nixmodule.EitherOption:
+		return option.Either
+	case nixmodule.CoercedToOption:
+		return []nixmodule.Option{option.From, option.To}
+	case nixmodule.FunctionToOption:
+		return []nixmodule.Option{option.FunctionTo}
+	case nixmodule.UnspecifiedOption:
+		return slices.Collect(maps.Values(option.NestedTypes))
+	default:
+		return nil
+	}
+}
+
+// isReferenced returns true if any [nixmodule.RefOption] within the given
+// option refers to it.
+func isReferenced(option nixmodule.Option) bool {
+	var refers func(o nixmodule.Option, depth int) bool
+	refers = func(o nixmodule.Option, depth int) bool {
+		if ref, ok := o.(nixmodule.RefOption); ok {
+			return ref.Up == depth
+		}
+		for _, nested := range nestedOptions(o) {
+			if refers(nested, depth+1) {
+				return true
+			}
+		}
+		return false
+	}
+	return refers(option, 0)
+}
+
+// recursiveTypeName returns the name of the Go type generated for an option
+// that refers to itself. Options that already generate a named type keep
+// that name, while other types are named with a Value suffix.
+func (g *generatingFile) recursiveTypeName(name optionName, option nixmodule.Option, opts []optionOpt) string {
+	switch option := option.(type) {
+	case nixmodule.EitherOption:
+		return name.Go + "JSON"
+	case nixmodule.SubmoduleOption:
+		if !buildOptionOpts(opts).forceInline {
+			return name.Go
+		}
+	case nixmodule.UniqueOption:
+		return g.recursiveTypeName(name, option.Unique, opts)
+	case nixmodule.CoercedToOption:
+		if g.opts.AcceptCoerced {
+			return name.Go + "JSON"
+		}
+		return g.recursiveTypeName(name, option.To, opts)
+	}
+	return name.Go + "Value"
+}
+
+// generateRecursiveType generates the type of an option that refers to
+// itself. The type is named before it's generated, so that the references
+// within it can use the name.
+func (g *generatingFile) generateRecursiveType(name optionName, path modulePath, option nixmodule.Option, opts ...optionOpt) string {
+	typeName := g.recursiveTypeName(name, option, opts)
+
+	g.ancestors = append(g.ancestors, typeName)
+	goType := g.generateOptionTypeOf(name, path, option, opts...)
+	g.ancestors = g.ancestors[:len(g.ancestors)-1]
+
+	if goType != typeName {
+		g.append(
+			gen.NewCommentf(" %s is the recursive type for %s.", typeName, path.GoDocForNixPath()),
+			gen.NewRawStatementf("type %s %s", typeName, goType),
+		)
+	}
+
+	return typeName
+}
+
+// generateRefType returns the name of the type that the given
+// [nixmodule.RefOption] refers to.
+func (g *generatingFile) generateRefType(path modulePath, ref nixmodule.RefOption) string {
+	i := len(g.ancestors) - ref.Up
+	if ref.Up < 1 || i < 0 || g.ancestors[i] == "" {
+		g.slog.Warn(
+			"option refers to a type that cannot be named, using raw JSON instead",
+			"path", path,
+			"up", ref.Up)
+		g.addImport("encoding/json")
+		return "json.RawMessage"
+	}
+	return g.ancestors[i]
+}
@@ -0,0 +1,63 @@
+package nixmod2go
+
+import (
+	"regexp"
+
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// wrapperKinds maps the functor names of Nix types that wrap a single
+// elemType to the prefix of the Go type of the element.
+var wrapperKinds = map[string]string{
+	"listOf":         "[]",
+	"nonEmptyListOf": "[]",
+	"attrsOf":        "map[string]",
+	"lazyAttrsOf":    "map[string]",
+	"attrsWith":      "map[string]",
+	"nullOr":         "*",
+}
+
+// wrapperDescription matches the descriptions of types that wrap a single
+// elemType, such as "non-empty list of string" or "lazy attribute set of
+// string".
+var wrapperDescription = regexp.MustCompile(`^(?:non-empty |lazy )?(list|attribute set) of |^null or `)
+
+// wrapperPrefix returns the prefix of the Go type of the element of an
+// unknown type that wraps a single elemType. The kind of wrapper is found
+// from its functor name, or else from its description.
+func wrapperPrefix(option nixmodule.UnspecifiedOption) (string, bool) {
+	if prefix, ok := wrapperKinds[option.Functor.Name]; ok {
+		return prefix, true
+	}
+
+	m := wrapperDescription.FindStringSubmatch(option.TypeDescription)
+	switch {
+	case m == nil:
+		return "", false
+	case m[1] == "list":
+		return "[]", true
+	case m[1] == "attribute set":
+		return "map[string]", true
+	default:
+		return "*", true
+	}
+}
+
+// generateUnspecifiedType guesses the Go type of a type that isn't known from
+// its nested types. Types that wrap a single elemType become a list, a map or
+// a pointer of it. Otherwise, the raw JSON is kept.
+func (g *generatingFile) generateUnspecifiedType(name optionName, path modulePath, option nixmodule.UnspecifiedOption, opts ...optionOpt) string {
+	if elem, ok := option.NestedTypes["elemType"]; ok && len(option.NestedTypes) == 1 {
+		if prefix, ok := wrapperPrefix(option); ok {
+			g.slog.Debug(
+				"guessed the Go type of an unknown type",
+				"path", path,
+				"functor", option.Functor.Name,
+				"description", option.TypeDescription)
+			return prefix + g.generateOptionType(name, path, elem, opts...)
+		}
+	}
+
+	g.addImport("encoding/json")
+	return "json.RawMessage"
+}
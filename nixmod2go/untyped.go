@@ -0,0 +1,78 @@
+package nixmod2go
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// generateUntypedType returns the Go type of an option declared without a
+// type, which is inferred from its default value. If the default isn't a
+// plain value or its type is ambiguous, such as for an empty list, any is
+// used instead.
+func (g *generatingFile) generateUntypedType(path modulePath, option nixmodule.UntypedOption) string {
+	if option.Default.Kind == nixmodule.LiteralValue {
+		if goType, ok := inferGoType(option.Default.Value); ok {
+			return goType
+		}
+	}
+
+	g.slog.Warn(
+		"option has no type and none can be inferred from its default, using any instead",
+		"path", path,
+		"default", option.Default.String())
+	return "any"
+}
+
+// inferGoType returns the Go type of the JSON value v if it's unambiguous.
+// Lists and attribute sets must not be empty, and all of their elements must
+// have the same Go type.
+func inferGoType(v jsontext.Value) (string, bool) {
+	switch v.Kind() {
+	case '"':
+		return "string", true
+	case 't', 'f':
+		return "bool", true
+	case '0':
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return "int", true
+		}
+		return "float64", true
+	case '[':
+		var elems []jsontext.Value
+		if err := json.Unmarshal(v, &elems); err != nil {
+			return "", false
+		}
+		return inferElemType("[]", elems)
+	case '{':
+		var attrs map[string]jsontext.Value
+		if err := json.Unmarshal(v, &attrs); err != nil {
+			return "", false
+		}
+		return inferElemType("map[string]", slices.Collect(maps.Values(attrs)))
+	default:
+		// null could be the default of any nullable type.
+		return "", false
+	}
+}
+
+// inferElemType returns the Go type of a container of elems, given the prefix
+// of the container type.
+func inferElemType(prefix string, elems []jsontext.Value) (string, bool) {
+	var elemType string
+	for _, elem := range elems {
+		t, ok := inferGoType(elem)
+		if !ok || (elemType != "" && t != elemType) {
+			return "", false
+		}
+		elemType = t
+	}
+	if elemType == "" {
+		return "", false
+	}
+	return prefix + elemType, true
+}
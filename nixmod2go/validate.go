@@ -0,0 +1,294 @@
+package nixmod2go
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/gotk4/gir/girgen/strcases"
+	gen "github.com/moznion/gowrtr/generator"
+	"libdb.so/nixmod2go/nixmodule"
+)
+
+// rangeCheck describes a numeric Go type whose values are limited to a range
+// that the type itself cannot express.
+type rangeCheck struct {
+	// GoType is the underlying Go type.
+	GoType string
+	// Conds are Go conditions on `v` that are true for invalid values.
+	Conds []string
+	// Desc describes the valid values, such as "between 1 and 10 (inclusive)".
+	Desc string
+}
+
+// intRange returns the rangeCheck of an integer between min and max, using the
+// narrowest Go integer type that fits the range.
+func intRange(min, max int64) rangeCheck {
+	type intType struct {
+		name     string
+		min, max int64
+	}
+
+	// Unsigned types can't represent negative minimums, and uint64 can't be
+	// represented within int64, so a plain uint is used as the largest.
+	types := []intType{
+		{"uint8", 0, math.MaxUint8},
+		{"uint16", 0, math.MaxUint16},
+		{"uint32", 0, math.MaxUint32},
+		{"uint", 0, math.MaxInt64},
+		{"int8", math.MinInt8, math.MaxInt8},
+		{"int16", math.MinInt16, math.MaxInt16},
+		{"int32", math.MinInt32, math.MaxInt32},
+		{"int64", math.MinInt64, math.MaxInt64},
+	}
+
+	t := types[len(types)-1]
+	for _, typ := range types {
+		if typ.min <= min && max <= typ.max {
+			t = typ
+			break
+		}
+	}
+
+	r := rangeCheck{
+		GoType: t.name,
+		Desc:   fmt.Sprintf("between %d and %d (inclusive)", min, max),
+	}
+	if min > t.min {
+		r.Conds = append(r.Conds, fmt.Sprintf("v < %d", min))
+	}
+	if max < t.max {
+		r.Conds = append(r.Conds, fmt.Sprintf("v > %d", max))
+	}
+	return r
+}
+
+// floatRange returns the rangeCheck of a number between min and max.
+func floatRange(min, max float64) rangeCheck {
+	return rangeCheck{
+		GoType: "float64",
+		Conds: []string{
+			"v < " + formatFloat(min),
+			"v > " + formatFloat(max),
+		},
+		Desc: fmt.Sprintf("between %s and %s (inclusive)", formatFloat(min), formatFloat(max)),
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// generateRangeType generates a named type for the given range with a
+// Validate method, named by [generatingFile.typeName]. If the Go type can
+// already represent exactly the range, the Go type is returned as-is.
+func (g *generatingFile) generateRangeType(name optionName, path modulePath, r rangeCheck) string {
+	if len(r.Conds) == 0 {
+		return r.GoType
+	}
+
+	typeName := g.typeName(name, path)
+	recv := strcases.FirstLetter(typeName)
+	conds := make([]string, len(r.Conds))
+	for i, cond := range r.Conds {
+		conds[i] = recv + strings.TrimPrefix(cond, "v")
+	}
+
+	g.addImport("fmt")
+	g.append(
+		gen.NewCommentf(" %s is the type for %s.", typeName, path.GoDocForNixPath()),
+		gen.NewCommentf(" Its values must be %s.", r.Desc),
+		gen.NewRawStatementf("type %s %s", typeName, r.GoType),
+		gen.NewNewline(),
+		gen.NewCommentf(" Validate returns an error if %s is not %s.", recv, r.Desc),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, typeName),
+			gen.NewFuncSignature("Validate").ReturnTypes("error"),
+			gen.NewIf(strings.Join(conds, " || "),
+				gen.NewReturnStatement(fmt.Sprintf("fmt.Errorf(%q, %s)", "%v is not "+r.Desc, recv)),
+			),
+			gen.NewReturnStatement("nil"),
+		),
+	)
+
+	g.validated[typeName] = struct{}{}
+	return typeName
+}
+
+// validateCode returns the Go code that validates expr of type typ, appending
+// errors to `errs`. errFormat and errArgs describe the value in errors. It
+// returns an empty string if typ has nothing to validate.
+func (g *generatingFile) validateCode(typ, expr, errFormat string, errArgs []string, depth int) string {
+	switch {
+	case strings.HasPrefix(typ, "*"):
+		inner := g.validateCode(typ[1:], "(*"+expr+")", errFormat, errArgs, depth)
+		if inner == "" {
+			return ""
+		}
+		return fmt.Sprintf("if %s != nil {\n%s}\n", expr, inner)
+
+	case strings.HasPrefix(typ, "[]"):
+		i, v := fmt.Sprintf("i%d", depth), fmt.Sprintf("v%d", depth)
+		inner := g.validateCode(typ[2:], v, errFormat+"[%d]", append(errArgs, i), depth+1)
+		if inner == "" {
+			return ""
+		}
+		return fmt.Sprintf("for %s, %s := range %s {\n%s}\n", i, v, expr, inner)
+
+	case strings.HasPrefix(typ, "map[string]"):
+		k, v := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		inner := g.validateCode(typ[len("map[string]"):], v, errFormat+"[%q]", append(errArgs, k), depth+1)
+		if inner == "" {
+			return ""
+		}
+		return fmt.Sprintf("for %s, %s := range %s {\n%s}\n", k, v, expr, inner)
+
+	default:
+		if _, ok := g.validated[typ]; !ok {
+			return ""
+		}
+		args := strings.Join(append(errArgs, "err"), ", ")
+		return fmt.Sprintf(
+			"if err := %s.Validate(); err != nil {\nerrs = append(errs, fmt.Errorf(%q, %s))\n}\n",
+			expr, errFormat+": %w", args)
+	}
+}
+
+// isRequired returns true if the option has no default value, so it must be
+// defined. Dumps without HasDefault still have Default if there is one.
+// Read-only and internal options are defined by modules rather than by users,
+// so they're never required.
+func isRequired(doc nixmodule.OptionDoc) bool {
+	if doc.ReadOnly || doc.Internal {
+		return false
+	}
+	return !doc.HasDefault && doc.Default.IsZero()
+}
+
+// requiredCode returns the Go code that appends an error to `errs` if the
+// required value expr of type typ isn't set. Only types whose zero value isn't
+// a valid Nix value can be checked, so it returns an empty string for others,
+// such as strings, numbers and nullable types.
+func (g *generatingFile) requiredCode(typ, expr, errName string) string {
+	var cond string
+	switch _, isEither := g.eitherTypes[typ]; {
+	case strings.HasPrefix(typ, "[]"), strings.HasPrefix(typ, "map["), typ == "json.RawMessage":
+		cond = expr + " == nil"
+	case isEither:
+		cond = expr + ".Value == nil"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("if %s {\nerrs = append(errs, errors.New(%q))\n}\n", cond, errName+": required but not set")
+}
+
+// generateValidateMethod generates a Validate method for the struct type
+// name, given the validation code of each of its fields. Nothing is generated
+// if no field needs validating.
+func (g *generatingFile) generateValidateMethod(name optionName, fields []string) []gen.Statement {
+	if strings.Join(fields, "") == "" {
+		return nil
+	}
+
+	g.addImport("errors")
+	g.validated[name.Go] = struct{}{}
+
+	stmts := []gen.Statement{gen.NewRawStatement("var errs []error")}
+	for _, field := range fields {
+		if field != "" {
+			stmts = append(stmts, gen.NewRawStatement(strings.TrimSuffix(field, "\n")))
+		}
+		// Required values are checked without fmt.
+		if strings.Contains(field, "fmt.") {
+			g.addImport("fmt")
+		}
+	}
+	stmts = append(stmts, gen.NewReturnStatement("errors.Join(errs...)"))
+
+	recv := strcases.FirstLetter(name.Go)
+	return []gen.Statement{
+		gen.NewNewline(),
+		gen.NewCommentf(" Validate returns an error if any value within %s is out of its allowed range,", recv),
+		gen.NewCommentf(" or if a required value isn't set."),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, name.Go),
+			gen.NewFuncSignature("Validate").ReturnTypes("error"),
+			stmts...,
+		),
+	}
+}
+
+// generateStrMatchingType generates a named string type whose values must
+// match the option's pattern, named by [generatingFile.typeName]. Nix matches
+// the whole string using POSIX extended regular expressions, so the pattern is
+// anchored and compiled with [regexp.CompilePOSIX]. If Go cannot compile the
+// pattern, the type accepts any string, which its doc comment says.
+func (g *generatingFile) generateStrMatchingType(name optionName, path modulePath, option nixmodule.StrMatchingOption) string {
+	typeName := g.typeName(name, path)
+	typeDoc := []gen.Statement{
+		gen.NewCommentf(" %s is the type for %s.", typeName, path.GoDocForNixPath()),
+		gen.NewCommentf(" Its values must match the pattern %s.", "`"+option.Pattern+"`"),
+	}
+
+	pattern := "^(" + option.Pattern + ")$"
+	if _, err := regexp.CompilePOSIX(pattern); err != nil {
+		g.slog.Warn(
+			"pattern cannot be compiled by Go, it won't be enforced",
+			"path", path,
+			"pattern", option.Pattern,
+			"err", err)
+		g.append(append(typeDoc,
+			gen.NewComment(""),
+			gen.NewComment(" Warning: Go cannot compile the pattern, so it isn't enforced."),
+			gen.NewRawStatementf("type %s string", typeName),
+		)...)
+		return typeName
+	}
+
+	recv := strcases.FirstLetter(typeName)
+	patternVar := strcases.UnexportPascal(typeName) + "Pattern"
+
+	g.addImport("encoding/json")
+	g.addImport("fmt")
+	g.addImport("regexp")
+	g.append(append(typeDoc,
+		gen.NewRawStatementf("type %s string", typeName),
+		gen.NewNewline(),
+		gen.NewRawStatementf("var %s = regexp.MustCompilePOSIX(%s)", patternVar, strconv.Quote(pattern)),
+		gen.NewNewline(),
+		gen.NewCommentf(" Validate returns an error if %s doesn't match its pattern.", recv),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, typeName),
+			gen.NewFuncSignature("Validate").ReturnTypes("error"),
+			gen.NewIf(fmt.Sprintf("!%s.MatchString(string(%s))", patternVar, recv),
+				gen.NewReturnStatement(fmt.Sprintf(
+					"fmt.Errorf(%q, string(%s), %s)",
+					"%q does not match the pattern %q", recv, strconv.Quote(option.Pattern))),
+			),
+			gen.NewReturnStatement("nil"),
+		),
+		gen.NewNewline(),
+		gen.NewCommentf(" UnmarshalJSON implements the [json.Unmarshaler] interface for [%s].", typeName),
+		gen.NewCommentf(" It returns an error if the value doesn't match the pattern."),
+		gen.NewFunc(
+			gen.NewFuncReceiver(recv, "*"+typeName),
+			gen.NewFuncSignature("UnmarshalJSON").
+				Parameters(gen.NewFuncParameter("data", "[]byte")).
+				ReturnTypes("error"),
+			gen.NewRawStatement("var s string"),
+			gen.NewIf("err := json.Unmarshal(data, &s); err != nil",
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewIf(fmt.Sprintf("err := %s(s).Validate(); err != nil", typeName),
+				gen.NewReturnStatement("err"),
+			),
+			gen.NewRawStatementf("*%s = %s(s)", recv, typeName),
+			gen.NewReturnStatement("nil"),
+		),
+	)...)
+
+	g.validated[typeName] = struct{}{}
+	return typeName
+}
@@ -0,0 +1,108 @@
+package nixmodule
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// Cache is an on-disk cache of dumped modules. A cached dump is returned
+// without running Nix if the dump_module.nix arguments, the contents of every
+// [ModulePath] and ExtraKeys are all unchanged.
+//
+// Files that are imported by a module, or referred to by a [ModuleExpr], are
+// not tracked, so editing them returns a stale dump. Callers should only use
+// a cache if they add something that changes along with them, such as the
+// narHash of the flake containing the module, to ExtraKeys.
+type Cache struct {
+	// Dir is the directory containing the cached dumps.
+	Dir string
+	// ExtraKeys are additional values that the cached dumps depend on, such
+	// as the narHash of each locked flake input.
+	ExtraKeys []string
+}
+
+// DumpModuleWithCache makes [DumpModule] use the given cache.
+func DumpModuleWithCache(cache Cache) DumpModuleOpt {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		s.cache = &cache
+		return nil
+	})
+}
+
+// key returns the cache key of the request. sources are the files whose
+// contents the request depends on.
+func (c Cache) key(req EvalRequest, sources []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", req.Expr)
+	for _, k := range slices.Sorted(maps.Keys(req.Args)) {
+		fmt.Fprintf(h, "arg %q=%q\n", k, req.Args[k])
+	}
+	for _, source := range sources {
+		b, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("cache: read module source: %w", err)
+		}
+		fmt.Fprintf(h, "source %q=%x\n", source, sha256.Sum256(b))
+	}
+	for _, k := range c.ExtraKeys {
+		fmt.Fprintf(h, "extra %q\n", k)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// get returns the cached dump for key, or nil if there is none.
+func (c Cache) get(ctx context.Context, key string) jsontext.Value {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.WarnContext(ctx,
+				"cannot read cached module dump",
+				"key", key,
+				"err", err)
+		}
+		return nil
+	}
+	return jsontext.Value(b)
+}
+
+// put stores the dump for key. The dump is written to a temporary file first,
+// so that concurrent readers never see a partial dump.
+func (c Cache) put(key string, v jsontext.Value) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+
+	f, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(v); err != nil {
+		f.Close()
+		return fmt.Errorf("cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package nixmodule
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var evals int
+	stub := stubEvaluator{
+		value: jsontext.Value(`{"hello":{"_option":true,"_type":"str"}}`),
+		evals: &evals,
+	}
+
+	module := filepath.Join(dir, "module.nix")
+	writeModule := func(src string) {
+		t.Helper()
+		err := os.WriteFile(module, []byte(src), 0o644)
+		assert.NoError(t, err)
+	}
+
+	dump := func(cache Cache) {
+		t.Helper()
+		m, _, err := DumpModule(ctx, ModulePath(module),
+			DumpModuleWithEvaluator(stub),
+			DumpModuleWithCache(cache))
+		assert.NoError(t, err)
+		assert.Equal(t, Module{"hello": StrOption{}}, m)
+	}
+
+	cache := Cache{Dir: filepath.Join(dir, "cache")}
+
+	writeModule(`{ lib, ... }: { options.hello = lib.mkOption { type = lib.types.str; }; }`)
+	dump(cache)
+	assert.Equal(t, 1, evals, "expected a cache miss")
+
+	dump(cache)
+	assert.Equal(t, 1, evals, "expected a cache hit")
+
+	writeModule(`{ lib, ... }: { options.hello = lib.mkOption { type = lib.types.str; default = ""; }; }`)
+	dump(cache)
+	assert.Equal(t, 2, evals, "expected a cache miss after the module changed")
+
+	cache.ExtraKeys = []string{"sha256-AAAA"}
+	dump(cache)
+	assert.Equal(t, 3, evals, "expected a cache miss after the extra keys changed")
+}
@@ -2,13 +2,14 @@ package nixmodule
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	_ "embed"
 
@@ -19,43 +20,154 @@ import (
 var dumpModuleNix NixExpr
 
 type argAdder interface {
-	add(ctx context.Context, cmd *exec.Cmd) error
+	add(ctx context.Context, s *dumpModuleState) error
+}
+
+// dumpModuleState is the state that [DumpModuleOpt]s and [ModuleInput]s
+// are applied to.
+type dumpModuleState struct {
+	evaluator Evaluator
+	request   EvalRequest
+	parses    []dumpModuleParse
+	cache     *Cache
+	sharding  *sharding
+	// optionsPath is the path given to DumpModuleWithOptionsPath.
+	optionsPath []string
+	// sources are the files that the module is read from.
+	sources []string
+
+	parseOnce sync.Once
+	parseErr  error
+}
+
+// dumpModuleParse is an expression that is parsed before evaluating, so
+// that syntax errors are reported along with what the expression is for.
+type dumpModuleParse struct {
+	expr NixExpr
+	what string
+}
+
+// addArg adds an argument to the dump_module.nix function call.
+// what describes the argument in errors if it fails to parse.
+func (s *dumpModuleState) addArg(name string, expr NixExpr, what string) {
+	s.request.Args[name] = expr
+	s.parse(expr, what)
+}
+
+// parse registers expr to be parsed before evaluating.
+func (s *dumpModuleState) parse(expr NixExpr, what string) {
+	s.parses = append(s.parses, dumpModuleParse{expr, what})
 }
 
 // ModuleInput represents an input to [DumpModule].
-// It can be either [ModulePath] or [ModuleExpr].
+// It can be either [ModulePath], [ModuleExpr], [ModuleList] or
+// [ModuleOptions].
 type ModuleInput interface {
 	isDumpModuleInput()
 	argAdder
 }
 
-func (ModulePath) isDumpModuleInput() {}
-func (ModuleExpr) isDumpModuleInput() {}
+// moduleExprer is implemented by [ModuleInput]s that can be written as a
+// single Nix expression. Only these can be used within a [ModuleList].
+type moduleExprer interface {
+	moduleExpr(s *dumpModuleState) (NixExpr, error)
+}
+
+func (ModulePath) isDumpModuleInput()    {}
+func (ModuleExpr) isDumpModuleInput()    {}
+func (ModuleList) isDumpModuleInput()    {}
+func (ModuleOptions) isDumpModuleInput() {}
 
 // ModulePath represents a path to a Nix module file.
 // The path doesn't have to be absolute.
 type ModulePath string
 
-func (p ModulePath) add(ctx context.Context, cmd *exec.Cmd) error {
+func (p ModulePath) moduleExpr(s *dumpModuleState) (NixExpr, error) {
 	abs, err := filepath.Abs(string(p))
 	if err != nil {
-		return fmt.Errorf("resolve module path: %w", err)
+		return "", fmt.Errorf("resolve module path: %w", err)
 	}
+	s.sources = append(s.sources, abs)
+	return NixExpr(abs), nil
+}
 
-	cmd.Args = append(cmd.Args, "--arg", "module", abs)
-	return nil
+func (p ModulePath) add(ctx context.Context, s *dumpModuleState) error {
+	return addModuleArg(s, p)
 }
 
 // ModuleExpr represents a Nix expression that evaluates to a Nix module.
-// The expression must be a function that accepts a `lib` argument and returns a
-// Nix module.
+// The module is evaluated using `lib.evalModules`, so it may be anything that
+// is accepted there: a module function, a plain attribute set or a path.
 type ModuleExpr NixExpr
 
-func (e ModuleExpr) add(ctx context.Context, cmd *exec.Cmd) error {
-	if err := NixExpr(e).Validate(ctx); err != nil {
-		return fmt.Errorf("parse module expression: %w", err)
+func (e ModuleExpr) moduleExpr(s *dumpModuleState) (NixExpr, error) {
+	s.parse(NixExpr(e), "parse module expression")
+	return NixExpr(e), nil
+}
+
+func (e ModuleExpr) add(ctx context.Context, s *dumpModuleState) error {
+	return addModuleArg(s, e)
+}
+
+// ModuleList represents a list of modules that are evaluated together, just
+// like the `modules` list given to `lib.evalModules`. The dumped [Module]
+// contains the combined options of all modules, so modules may extend options
+// declared by other modules in the list.
+type ModuleList []ModuleInput
+
+func (l ModuleList) moduleExpr(s *dumpModuleState) (NixExpr, error) {
+	var b strings.Builder
+	b.WriteString("[ ")
+	for i, input := range l {
+		exprer, ok := input.(moduleExprer)
+		if !ok {
+			return "", fmt.Errorf("module list: %T at index %d cannot be used in a list", input, i)
+		}
+
+		// Parse each module on its own so that errors point to the right
+		// index.
+		parses := len(s.parses)
+		expr, err := exprer.moduleExpr(s)
+		if err != nil {
+			return "", fmt.Errorf("module list: error at index %d: %w", i, err)
+		}
+		for j := parses; j < len(s.parses); j++ {
+			s.parses[j].what = fmt.Sprintf("module list: error at index %d: %s", i, s.parses[j].what)
+		}
+
+		// Put the closing parenthesis on a new line in case the expression ends
+		// with a comment.
+		fmt.Fprintf(&b, "(%s\n) ", expr)
 	}
-	cmd.Args = append(cmd.Args, "--arg", "module", string(e))
+	b.WriteString("]")
+	return NixExpr(b.String()), nil
+}
+
+func (l ModuleList) add(ctx context.Context, s *dumpModuleState) error {
+	return addModuleArg(s, l)
+}
+
+// ModuleOptions represents a Nix expression that evaluates to an option tree
+// that is already evaluated, such as `nixosConfigurations.<host>.options` or
+// `homeConfigurations.<user>.options`. Unlike [ModuleExpr], the expression is
+// not evaluated as a module, so the options of every module within the
+// configuration are dumped as-is.
+//
+// ModuleOptions cannot be used within a [ModuleList].
+type ModuleOptions NixExpr
+
+func (e ModuleOptions) add(ctx context.Context, s *dumpModuleState) error {
+	s.addArg("evaluatedOptions", NixExpr(e), "parse options expression")
+	return nil
+}
+
+func addModuleArg(s *dumpModuleState, input moduleExprer) error {
+	expr, err := input.moduleExpr(s)
+	if err != nil {
+		return err
+	}
+	// Each module is already parsed on its own.
+	s.request.Args["module"] = expr
 	return nil
 }
 
@@ -65,19 +177,27 @@ type DumpModuleOpt interface {
 	argAdder
 }
 
-type dumpModuleOptFunc func(ctx context.Context, cmd *exec.Cmd) error
+type dumpModuleOptFunc func(ctx context.Context, s *dumpModuleState) error
+
+func (f dumpModuleOptFunc) isDumpModuleOpt() {}
+func (f dumpModuleOptFunc) add(ctx context.Context, s *dumpModuleState) error {
+	return f(ctx, s)
+}
 
-func (f dumpModuleOptFunc) isDumpModuleOpt()                             {}
-func (f dumpModuleOptFunc) add(ctx context.Context, cmd *exec.Cmd) error { return f(ctx, cmd) }
+// DumpModuleWithEvaluator sets the [Evaluator] that is used to evaluate the
+// module. By default, [DefaultEvaluator] is used.
+func DumpModuleWithEvaluator(evaluator Evaluator) DumpModuleOpt {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		s.evaluator = evaluator
+		return nil
+	})
+}
 
 // DumpModuleWithPkgs adds a `pkgs` argument to the Nix expression.
 // By default, <nixpkgs> is used.
 func DumpModuleWithPkgs(expr NixExpr) DumpModuleOpt {
-	return dumpModuleOptFunc(func(ctx context.Context, cmd *exec.Cmd) error {
-		if err := expr.Validate(ctx); err != nil {
-			return fmt.Errorf("parse pkgs expression: %w", err)
-		}
-		cmd.Args = append(cmd.Args, "--arg", "pkgs", string(expr))
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		s.addArg("pkgs", expr, "parse pkgs expression")
 		return nil
 	})
 }
@@ -85,27 +205,22 @@ func DumpModuleWithPkgs(expr NixExpr) DumpModuleOpt {
 // DumpModuleWithSpecialArgs adds a `specialArgs` argument to the Nix expression.
 // By default, { } is used.
 func DumpModuleWithSpecialArgs(specialArgs map[string]NixExpr) DumpModuleOpt {
-	return dumpModuleOptFunc(func(ctx context.Context, cmd *exec.Cmd) error {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
 		slogAttrs := make([]any, 0, len(specialArgs))
 
 		var b strings.Builder
 		b.WriteString("{ ")
-		for k, v := range specialArgs {
-			if err := v.Validate(ctx); err != nil {
-				return fmt.Errorf("specialArgs: error at %q: %w", k, err)
-			}
-			fmt.Fprintf(&b, "%q = %s; ", k, v)
+		for _, k := range slices.Sorted(maps.Keys(specialArgs)) {
+			v := specialArgs[k]
+			s.parse(v, fmt.Sprintf("specialArgs: error at %q", k))
+			fmt.Fprintf(&b, "%q = (%s\n); ", k, v)
 			slogAttrs = append(slogAttrs, slog.String("specialArgs."+k, string(v)))
 		}
 		b.WriteString(" }")
 
 		slog.DebugContext(ctx, "built specialArgs expression", slogAttrs...)
 
-		if err := NixExpr(b.String()).Validate(ctx); err != nil {
-			return fmt.Errorf("specialArgs: %w", err)
-		}
-
-		cmd.Args = append(cmd.Args, "--arg", "specialArgs", b.String())
+		s.addArg("specialArgs", NixExpr(b.String()), "specialArgs")
 		return nil
 	})
 }
@@ -113,29 +228,58 @@ func DumpModuleWithSpecialArgs(specialArgs map[string]NixExpr) DumpModuleOpt {
 // DumpModuleWithOptionsPath adds an `optionsPath` argument to the Nix
 // expression.
 func DumpModuleWithOptionsPath(path []string) DumpModuleOpt {
-	return dumpModuleOptFunc(func(ctx context.Context, cmd *exec.Cmd) error {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		expr := nixStringList(path)
+
+		slog.DebugContext(ctx,
+			"built optionsPath expression",
+			"optionsPath", expr)
+
+		s.request.Args["optionsPath"] = expr
+		s.optionsPath = path
+		return nil
+	})
+}
+
+// DumpModuleWithLocationRoots adds a `locationRoots` argument to the Nix
+// expression. The files of option locations and declarations within any of
+// the roots, which are Nix expressions of paths or strings, are made relative
+// to the first one they're within. By default, files are absolute.
+func DumpModuleWithLocationRoots(roots ...NixExpr) DumpModuleOpt {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
 		var b strings.Builder
 		b.WriteString("[ ")
-		for _, v := range path {
-			fmt.Fprintf(&b, "%q ", v)
+		for i, root := range roots {
+			s.parse(root, fmt.Sprintf("locationRoots: error at %d", i))
+			fmt.Fprintf(&b, "(%s\n) ", root)
 		}
 		b.WriteString("]")
 
 		slog.DebugContext(ctx,
-			"built optionsPath expression",
-			"optionsPath", b.String())
+			"built locationRoots expression",
+			"locationRoots", b.String())
 
-		cmd.Args = append(cmd.Args, "--arg", "optionsPath", b.String())
+		s.request.Args["locationRoots"] = NixExpr(b.String())
 		return nil
 	})
 }
 
-// DumpModuleWithStderrPassthrough redirects the standard error of the
-// `nix-instantiate` command to the standard error of the current process.
+// nixStringList returns a Nix list expression of the given strings.
+func nixStringList(strs []string) NixExpr {
+	var b strings.Builder
+	b.WriteString("[ ")
+	for _, v := range strs {
+		fmt.Fprintf(&b, "%q ", v)
+	}
+	b.WriteString("]")
+	return NixExpr(b.String())
+}
+
+// DumpModuleWithStderrPassthrough copies the diagnostic output of the
+// [Evaluator] to the standard error of the current process.
 func DumpModuleWithStderrPassthrough() DumpModuleOpt {
-	return dumpModuleOptFunc(func(ctx context.Context, cmd *exec.Cmd) error {
-		cmd.Stderr = os.Stderr
-		cmd.Args = append(cmd.Args, "--show-trace")
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		s.request.Stderr = os.Stderr
 		return nil
 	})
 }
@@ -148,9 +292,9 @@ func (opts *DumpModuleOpts) Add(more ...DumpModuleOpt) {
 	*opts = append(*opts, more...)
 }
 
-func (opts DumpModuleOpts) add(ctx context.Context, cmd *exec.Cmd) error {
+func (opts DumpModuleOpts) add(ctx context.Context, s *dumpModuleState) error {
 	for _, opt := range opts {
-		if err := opt.add(ctx, cmd); err != nil {
+		if err := opt.add(ctx, s); err != nil {
 			return err
 		}
 	}
@@ -159,69 +303,141 @@ func (opts DumpModuleOpts) add(ctx context.Context, cmd *exec.Cmd) error {
 
 func (opts DumpModuleOpts) isDumpModuleOpt() {}
 
-// DumpModule evaluates a Nix module and returns its representation as a
-// [Module].
-func DumpModule(ctx context.Context, module ModuleInput, opts ...DumpModuleOpt) (Module, error) {
-	return dumpModuleAs[Module](ctx, module, opts...)
+// DumpModule evaluates a Nix module using `lib.evalModules` and returns its
+// representation as a [Module]. The module's imports are evaluated as well.
+//
+// Options that could not be dumped precisely are reported as warnings. They are
+// also kept in each option's [OptionDoc].
+//
+// If [DumpModuleWithShards] is given and some shards fail, the options of the
+// other shards are still returned along with the error.
+func DumpModule(ctx context.Context, module ModuleInput, opts ...DumpModuleOpt) (Module, []Warning, error) {
+	s, err := newDumpModuleState(ctx, module, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.sharding != nil {
+		m, err := dumpModuleShards(ctx, s)
+		return m, collectWarnings(m), err
+	}
+
+	m, err := evalDumpModule[Module](ctx, s, s.request)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, collectWarnings(m), nil
+}
+
+// collectWarnings returns the warnings of all options within the given
+// module, outermost first.
+func collectWarnings(m Module) []Warning {
+	var warnings []Warning
+	for _, o := range m.All() {
+		warnings = append(warnings, o.Doc().Warnings...)
+	}
+	return warnings
 }
 
 func dumpModuleAs[T any](ctx context.Context, module ModuleInput, opts ...DumpModuleOpt) (T, error) {
-	var v T
+	s, err := newDumpModuleState(ctx, module, opts)
+	if err != nil {
+		var z T
+		return z, err
+	}
+	return evalDumpModule[T](ctx, s, s.request)
+}
 
-	cmd := exec.CommandContext(ctx,
-		"nix-instantiate",
-		"--eval", "--strict", "--json", "--expr")
+func newDumpModuleState(ctx context.Context, module ModuleInput, opts []DumpModuleOpt) (*dumpModuleState, error) {
+	s := &dumpModuleState{
+		evaluator: DefaultEvaluator,
+		request: EvalRequest{
+			Expr: dumpModuleNix,
+			Args: make(map[string]NixExpr),
+		},
+	}
 
-	if err := module.add(ctx, cmd); err != nil {
-		return v, err
+	if err := module.add(ctx, s); err != nil {
+		return nil, err
 	}
 
 	for _, opt := range opts {
-		if err := opt.add(ctx, cmd); err != nil {
-			return v, err
+		if err := opt.add(ctx, s); err != nil {
+			return nil, err
 		}
 	}
 
-	cmd.Args = append(cmd.Args, string(dumpModuleNix))
+	return s, nil
+}
+
+// parseAll parses all registered expressions once. It's only done right before
+// the first evaluation, so that cached dumps don't need Nix at all.
+func (s *dumpModuleState) parseAll(ctx context.Context) error {
+	s.parseOnce.Do(func() {
+		for _, parse := range s.parses {
+			if err := s.evaluator.Parse(ctx, parse.expr); err != nil {
+				s.parseErr = fmt.Errorf("%s: %w", parse.what, err)
+				return
+			}
+		}
+	})
+	return s.parseErr
+}
 
-	var stderr strings.Builder
-	if cmd.Stderr == nil {
-		cmd.Stderr = &stderr
+// evalDumpModule evaluates req, which is s.request with possibly different
+// arguments, and unmarshals the result into T.
+func evalDumpModule[T any](ctx context.Context, s *dumpModuleState, req EvalRequest) (T, error) {
+	var v T
+
+	var cacheKey string
+	if s.cache != nil {
+		key, err := s.cache.key(req, s.sources)
+		if err != nil {
+			return v, err
+		}
+		cacheKey = key
+
+		if out := s.cache.get(ctx, cacheKey); out != nil {
+			err := json.Unmarshal(out, &v, JSONOptions)
+			if err == nil {
+				slog.DebugContext(ctx,
+					"using cached module dump",
+					"key", cacheKey)
+				return v, nil
+			}
+			slog.WarnContext(ctx,
+				"ignoring invalid cached module dump",
+				"key", cacheKey,
+				"err", err)
+			v = *new(T)
+		}
 	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return v, fmt.Errorf("get stdout pipe: %w", err)
+	if err := s.parseAll(ctx); err != nil {
+		return v, err
 	}
-	defer stdout.Close()
 
 	slog.DebugContext(ctx,
-		"running nix-instantiate to dump modules",
-		"args", cmd.Args[:len(cmd.Args)-1])
+		"evaluating dump_module.nix",
+		"evaluator", fmt.Sprintf("%T", s.evaluator),
+		"args", slices.Sorted(maps.Keys(req.Args)))
 
-	if err := cmd.Start(); err != nil {
-		return v, fmt.Errorf("start nix-instantiate: %w", err)
+	out, err := s.evaluator.Eval(ctx, req)
+	if err != nil {
+		return v, err
 	}
 
-	jsonErr := json.UnmarshalRead(stdout, &v, JSONOptions)
-
-	if err := cmd.Wait(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			nixErrorMatch := nixErrorRe.FindStringSubmatch(stderr.String())
-			if nixErrorMatch == nil {
-				return v, fmt.Errorf("nix-instantiate: %s", stderr.String())
-			}
-
-			nixError := string(nixErrorMatch[1])
-			return v, fmt.Errorf("nix-instantiate: %s", nixError)
-		}
-
-		return v, fmt.Errorf("nix-instantiate: %w", err)
+	if err := json.Unmarshal(out, &v, JSONOptions); err != nil {
+		return v, fmt.Errorf("unmarshal module: %w", err)
 	}
 
-	if jsonErr != nil {
-		return v, fmt.Errorf("unmarshal module: %w", jsonErr)
+	if s.cache != nil {
+		if err := s.cache.put(cacheKey, out); err != nil {
+			slog.WarnContext(ctx,
+				"cannot cache module dump",
+				"key", cacheKey,
+				"err", err)
+		}
 	}
 
 	return v, nil
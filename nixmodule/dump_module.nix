@@ -1,9 +1,23 @@
 {
-  module,
+  module ? [ ],
+  # An already-evaluated option tree, such as
+  # nixosConfigurations.<host>.options. If given, module is ignored.
+  evaluatedOptions ? null,
 
   pkgs ? import <nixpkgs> { },
   specialArgs ? { },
   optionsPath ? [ ],
+  # If given, only the options with these names within optionsPath are
+  # dumped.
+  optionNames ? null,
+  # If given, the option tree is not dumped. Instead, it is split into shards
+  # of up to this depth, and a list of { path, names } is returned. Each shard
+  # can then be dumped using optionsPath and optionNames.
+  shardDepth ? null,
+  # The files of option declarations within any of these directories are
+  # made relative to the first one they're within, so that dumps don't depend
+  # on where the modules are checked out.
+  locationRoots ? [ ],
 }:
 
 with pkgs.lib;
@@ -12,30 +26,183 @@ with builtins;
 let
   lib = pkgs.lib;
 
-  module' = (if isPath module then import module else module) (
-    {
-      config = { };
-      options = { };
-    }
-    // (specialArgs)
-    // ({ inherit lib; })
-  );
+  # Evaluate the module the same way NixOS does, so that imports,
+  # disabledModules, _file, key, plain attrset modules and _module.args all
+  # behave as expected.
+  options =
+    if evaluatedOptions != null then
+      evaluatedOptions
+    else
+      (lib.evalModules {
+        # module may also be a (nested) list of modules.
+        modules = flatten (toList module);
+        specialArgs = specialArgs // {
+          lib = markUntyped (specialArgs.lib or lib);
+        };
+      }).options;
+
+  # markUntyped returns base with a mkOption that marks whether options are
+  # declared with a type, since lib.evalModules gives untyped options
+  # types.unspecified. Only the declaration is marked, so that declarations of
+  # the same option in other modules still merge the way they otherwise would.
+  # Modules that get mkOption elsewhere than from the lib module argument, such
+  # as from pkgs.lib or an imported lib, aren't marked.
+  markUntyped =
+    base:
+    let
+      mkOption =
+        attrs:
+        base.mkOption attrs
+        // (if attrs ? type then { _nixmod2goTyped = true; } else { _nixmod2goUntyped = true; });
+    in
+    base
+    // {
+      inherit mkOption;
+      options = base.options // {
+        inherit mkOption;
+      };
+    };
+
+  # isUntyped returns true if the option was declared without a type. Merging
+  # the declarations of an option keeps the attributes of all of them, so an
+  # option declared by several modules is only untyped if none of them gives a
+  # type, including the ones that aren't marked.
+  isUntyped =
+    option:
+    !(option ? type)
+    || (
+      option ? _nixmod2goUntyped && !(option ? _nixmod2goTyped) && option.type.name == "unspecified"
+    );
 
-  parseOptions = options: parseOptions' (filterAttrs (k: v: k != "_module") options);
-  parseOptions' = options: mapAttrs (name: parseOption) options;
+  # relativeFile makes file relative to the first of locationRoots that it's
+  # within, or returns it as-is.
+  relativeFile =
+    file:
+    let
+      root = findFirst (root: hasPrefix "${toString root}/" file) null locationRoots;
+    in
+    if root == null then file else removePrefix "${toString root}/" file;
+
+  # withContext adds the option path to errors that occur while evaluating
+  # any attribute of the dumped option. Attributes are evaluated lazily, so
+  # wrapping only the result isn't enough.
+  withContext =
+    path: value:
+    let
+      context = "while dumping the option `${showOption path}'";
+    in
+    addErrorContext context (
+      if isAttrs value then mapAttrs (_: addErrorContext context) value else value
+    );
+
+  # path is the option path of the option being parsed. Elements of listOf
+  # and attrsOf types are denoted by "*" and "<name>" respectively.
+  # ancestors is the list of types that the option is nested within,
+  # innermost first.
+  parseOptions =
+    ancestors: path: options:
+    parseOptions' ancestors path (
+      filterAttrs (
+        k: v:
+        !(elem k [
+          "_module"
+          "_freeformOptions"
+        ])
+      ) options
+    );
+  parseOptions' =
+    ancestors: path: options: mapAttrs (name: parseOption ancestors (path ++ [ name ])) options;
 
-  parseOption =
+  # formatOf returns the name of the pkgs.formats generator that created the
+  # type, or null. Format types are recursive and carry no name of their own,
+  # so they're recognized by their descriptions.
+  formatOf =
     option:
+    let
+      description = option.description or "";
+      elemType = option.nestedTypes.elemType or { };
+      elemDescription = elemType.description or "";
+      isAttrsOf = elem option.name [
+        "attrsOf"
+        "lazyAttrsOf"
+      ];
+    in
+    if description == "JSON value" then
+      "json"
+    else if match "YAML( 1\\.[12])? value" description != null then
+      "yaml"
+    else if description == "TOML value" then
+      "toml"
+    else if
+      isAttrsOf
+      && elem (elemType.name or null) [
+        "attrsOf"
+        "lazyAttrsOf"
+      ]
+      && hasInfix "INI atom" (elemType.nestedTypes.elemType.description or "")
+    then
+      "ini"
+    else if isAttrsOf && match "(list of )?atom \\(.*" elemDescription != null then
+      "keyValue"
+    else
+      null;
+
+  # sameType returns true if a and b are the same type value, including
+  # copies of it with overridden attributes. Types can't be compared as a
+  # whole, since recursive types never end, so only their check and merge
+  # functions are compared. Functions are only ever equal to themselves,
+  # which makes this an identity check.
+  sameType =
+    a: b:
+    let
+      identity = intersectAttrs {
+        check = null;
+        merge = null;
+      };
+    in
+    a ? check && a ? merge && identity a == identity b;
+
+  # refTo returns how many levels up within ancestors the type is found, or
+  # null if it isn't within its own ancestors.
+  refTo =
+    ancestors: type:
+    let
+      found = filter (i: sameType type (elemAt ancestors i)) (range 0 (length ancestors - 1));
+    in
+    if found == [ ] then null else head found + 1;
+
+  # tooDeep returns true if path is within too many nested listOf or attrsOf
+  # types, or if there are too many ancestors. Recursion that refTo can't
+  # detect, such as submodules that nest themselves, would otherwise be
+  # unrolled forever.
+  tooDeep =
+    ancestors: path: count (p: p == "*" || p == "<name>") path > 8 || length ancestors > 32;
+
+  parseOption = ancestors: path: option: withContext path (parseOption' ancestors path option);
+  parseOption' =
+    ancestors: path: option:
+    let
+      # The ancestors of the types nested within this one.
+      ancestors' = [ option ] ++ ancestors;
+    in
     if (option ? _type) then
       if (option._type == "option") then
         ({ })
-        // (parseOption option.type)
+        // (
+          if isUntyped option then
+            {
+              _option = true;
+              _type = "untyped";
+            }
+          else
+            parseOption ancestors path option.type
+        )
+        // (dumpLiteral option "default")
+        // (dumpLiteral option "defaultText")
+        // (dumpLiteral option "example")
         // (flip filterAttrs option (
           k: _:
           elem k [
-            "default"
-            "defaultText"
-            "example"
             "description"
             "descriptionClass"
             "visible"
@@ -43,21 +210,71 @@ let
             "readOnly"
           ]
         ))
+        // optionalAttrs (isAttrs (option.description or null)) {
+          # Older modules give descriptions using lib.mdDoc.
+          description = option.description.text or "";
+        }
+        // {
+          # default may be null or false, so its presence is recorded.
+          hasDefault = option ? default;
+        }
         // (
           let
-            rs = tryEval (builtins.unsafeGetAttrPos "type" option);
-            ok = rs.success && rs.value ? file && !(hasPrefix (toString pkgs.path) rs.value.file);
+            # Prefer declarationPositions, which has lines and columns, over
+            # declarations, which only has files. Declarations of anonymous
+            # modules have no real file, so skip them.
+            declarations = filter (pos: hasPrefix "/" pos.file) (
+              map (
+                pos:
+                {
+                  file = toString pos.file;
+                }
+                // optionalAttrs (pos.line or null != null) {
+                  inherit (pos) line column;
+                }
+              ) (option.declarationPositions or (map (file: { inherit file; }) (option.declarations or [ ])))
+            );
+            # Options declared within Nixpkgs are not interesting.
+            locations = filter (
+              pos: pos ? line && !(hasPrefix (toString pkgs.path) pos.file)
+            ) declarations;
+            relative = pos: pos // { file = relativeFile pos.file; };
           in
-          optionalAttrs ok {
-            location = {
-              inherit (rs.value) line column;
-            };
+          optionalAttrs (declarations != [ ]) {
+            declarations = map relative declarations;
+          }
+          // optionalAttrs (locations != [ ]) {
+            location = relative (head locations);
           }
         )
+      else if (option._type == "option-type" && formatOf option != null) then
+        {
+          _option = true;
+          _type = "format";
+          format = formatOf option;
+        }
+      else if (option._type == "option-type" && refTo ancestors option != null) then
+        {
+          _option = true;
+          _type = "ref";
+          up = refTo ancestors option;
+        }
+      else if (option._type == "option-type" && tooDeep ancestors path) then
+        {
+          _option = true;
+          _type = "unspecified";
+          _warnings = [
+            {
+              inherit path;
+              type = option.name;
+              reason = "option type ${option.name} is nested too deeply, it may be recursive";
+            }
+          ];
+        }
       else if (option._type == "option-type") then
         ({
           _option = true;
-          _type = option.name;
+          _type = typeName option;
         })
         // (
           {
@@ -71,9 +288,9 @@ let
             anything = { };
             # boolByOr = { };
             unspecified = { };
-            # Integer types, some of which have extra information that we
-            # unfortunately can't get:
-            intBetween = { };
+            # Integer types. The bounds of ints.between are only kept in its
+            # description, so they are parsed from there.
+            intBetween = parseBetween path option;
             positiveInt = { };
             signedInt16 = { };
             signedInt32 = { };
@@ -82,35 +299,218 @@ let
             unsignedInt32 = { };
             unsignedInt8 = { };
             unsignedInt = { };
+            # Number types, which are either an integer or a float:
+            numberBetween = parseBetween path option;
+            numberPositive = { };
+            numberNonnegative = { };
             # Types that have extra non-type information:
-            enum.enum = option.functor.payload;
+            # Newer versions of Nixpkgs wrap the values within an attribute set.
+            enum.enum = option.functor.payload.values or option.functor.payload;
             separatedString.separator = option.functor.payload;
+            strMatching.pattern =
+              option.functor.payload.pattern or (removePrefix "string matching the pattern " option.description);
             # Types that have more types underneath:
-            either.either = flattenEither option;
-            unique.unique = parseOption option.nestedTypes.elemType;
-            nullOr.nullOr = parseOption option.nestedTypes.elemType;
-            listOf.listOf = parseOption option.nestedTypes.elemType;
-            attrsOf.attrsOf = parseOption option.nestedTypes.elemType;
-            submodule.submodule = parseOptions (option.getSubOptions [ ]);
+            either.either = flattenEither ancestors' path option;
+            unique.unique = parseOption ancestors' path option.nestedTypes.elemType;
+            nullOr.nullOr = parseOption ancestors' path option.nestedTypes.elemType;
+            listOf.listOf = parseOption ancestors' (path ++ [ "*" ]) option.nestedTypes.elemType;
+            attrsOf.attrsOf = parseOption ancestors' (path ++ [ "<name>" ]) option.nestedTypes.elemType;
+            submodule =
+              let
+                subOptions = option.getSubOptions [ ];
+                # The freeform type is declared like a regular option within
+                # the submodule.
+                freeformType = tryEval subOptions._module.freeformType.value;
+              in
+              {
+                submodule = parseOptions ancestors' path subOptions;
+              }
+              // optionalAttrs (freeformType.success && freeformType.value != null) {
+                # The freeform type is usually an attrsOf type, which adds
+                # the <name> of undeclared attributes to the path itself.
+                freeform = parseOption ancestors' path freeformType.value;
+              };
+            # Values of these types are Nix modules and functions, which
+            # can't be represented as JSON.
+            deferredModule = { };
+            functionTo.functionTo = parseOption ancestors' path option.nestedTypes.elemType;
+            coercedTo = {
+              from = parseOption ancestors' path option.nestedTypes.coercedType;
+              to = parseOption ancestors' path option.nestedTypes.finalType;
+            };
           }
-          .${option.name} or (warn "Option type ${option.name} is not fully implemented" { })
+          .${typeName option} or (parseUnknown ancestors' path option)
         )
       else
         throw "Unknown option type: ${option._type}"
     else
-      parseOptions option;
+      parseOptions ancestors path option;
+
+  # parseUnknown dumps what can be found out about a type that isn't known,
+  # so that its Go type can still be guessed. Its nested types are dumped like
+  # any other type.
+  parseUnknown =
+    ancestors: path: option:
+    {
+      _warnings = [
+        {
+          inherit path;
+          type = option.name;
+          reason = "option type ${option.name} is not fully implemented";
+        }
+      ];
+    }
+    // optionalAttrs (option ? description) {
+      typeDescription = option.description;
+    }
+    // optionalAttrs (option ? functor.name) {
+      functor = {
+        inherit (option.functor) name;
+      }
+      // optionalAttrs (option.functor.payload or null != null) {
+        payload = toPlain 0 option.functor.payload;
+      };
+    }
+    // optionalAttrs (option.nestedTypes or { } != { }) {
+      nestedTypes = mapAttrs (_: parseOption ancestors path) option.nestedTypes;
+    };
+
+  # dumpLiteral dumps the default, defaultText or example of an option. Each
+  # one is checked on its own, so that one that can't be converted to JSON
+  # doesn't abort the whole dump. It's replaced with a marker that tells why,
+  # or left out if it's the default and defaultText documents it instead.
+  dumpLiteral =
+    option: name:
+    let
+      reason = unserializable 0 option.${name};
+    in
+    optionalAttrs (option ? ${name} && !(reason != null && name == "default" && option ? defaultText)) {
+      ${name} = if reason == null then option.${name} else { _unserializable = reason; };
+    };
+
+  # unserializable returns why v can't be converted to JSON, or null if it
+  # can. Values are forced one at a time using tryEval, so that values that
+  # throw are caught. Derivations aren't forced at all, since evaluating them
+  # may be expensive.
+  unserializable =
+    depth: v:
+    let
+      forced = tryEval v;
+      v' = forced.value;
+      firstReason = findFirst (reason: reason != null) null;
+    in
+    if !forced.success then
+      "error"
+    else if isFunction v' then
+      "lambda"
+    else if isDerivation v' then
+      "derivation"
+    else if (isAttrs v' || isList v') && depth > 16 then
+      "tooDeep"
+    else if isAttrs v' && v' ? outPath then
+      # Attribute sets with an outPath are converted to it.
+      unserializable (depth + 1) v'.outPath
+    else if isAttrs v' then
+      firstReason (mapAttrsToList (_: unserializable (depth + 1)) v')
+    else if isList v' then
+      firstReason (map (unserializable (depth + 1)) v')
+    else
+      null;
+
+  # toPlain replaces the values within v that can't be converted to JSON,
+  # such as functions, types and derivations, with null.
+  toPlain =
+    depth: v:
+    if isFunction v || isDerivation v || (isAttrs v && v ? _type) then
+      null
+    else if (isAttrs v || isList v) && depth > 4 then
+      null
+    else if isAttrs v then
+      mapAttrs (_: toPlain (depth + 1)) v
+    else if isList v then
+      map (toPlain (depth + 1)) v
+    else if isPath v then
+      toString v
+    else
+      v;
+
+  # typeName returns the name of the type without its arguments. Some types,
+  # such as strMatching, have their arguments within their names. Types that
+  # are dumped the same way as another type are given its name.
+  typeName =
+    option:
+    if option.functor.name or null == "strMatching" || hasPrefix "strMatching " option.name then
+      "strMatching"
+    # lazyAttrsOf only differs from attrsOf in how it's evaluated.
+    else if option.name == "lazyAttrsOf" then
+      "attrsOf"
+    # submoduleWith is usually named submodule already, and getSubOptions
+    # evaluates it with its own modules and specialArgs either way.
+    else if option.name == "submoduleWith" then
+      "submodule"
+    else
+      option.name;
+
+  # parseBetween parses the bounds of ints.between and numbers.between from
+  # their descriptions, such as "integer between 1 and 10 (both inclusive)".
+  parseBetween =
+    path: option:
+    let
+      bounds = match ".*between (-?[0-9.e+-]+) and (-?[0-9.e+-]+).*" option.description;
+    in
+    if bounds != null then
+      {
+        min = fromJSON (elemAt bounds 0);
+        max = fromJSON (elemAt bounds 1);
+      }
+    else
+      {
+        _warnings = [
+          {
+            inherit path;
+            type = option.name;
+            reason = "cannot find the bounds of ${option.name} in its description";
+          }
+        ];
+      };
 
   flattenEither = flattenEither';
 
   flattenEither' =
-    eitherOption:
+    ancestors: path: eitherOption:
     let
       l = eitherOption.nestedTypes.left;
       r = eitherOption.nestedTypes.right;
-      f = v: if v.name == "either" then flattenEither' v else [ (parseOption v) ];
+      f = v: if v.name == "either" then flattenEither' ancestors path v else [ (parseOption ancestors path v) ];
     in
     [ ] ++ f l ++ f r;
+
+  # listShards splits options into shards. Namespaces are split further until
+  # depth reaches 1, and the options next to them are kept together.
+  listShards =
+    depth: path: options:
+    let
+      names = filter (name: name != "_module") (attrNames options);
+      isNamespace = name: isAttrs options.${name} && !(isOption options.${name});
+      namespaces = filter isNamespace names;
+      leaves = filter (name: !(isNamespace name)) names;
+    in
+    if depth <= 1 then
+      map (name: {
+        inherit path;
+        names = [ name ];
+      }) names
+    else
+      optional (leaves != [ ]) {
+        inherit path;
+        names = leaves;
+      }
+      ++ concatMap (name: listShards (depth - 1) (path ++ [ name ]) options.${name}) namespaces;
+
+  root = attrsets.getAttrFromPath optionsPath options;
 in
 
-assert assertMsg (!(module' ? imports)) "imports is not supported";
-parseOptions (attrsets.getAttrFromPath optionsPath module'.options)
+if shardDepth != null then
+  listShards shardDepth optionsPath root
+else
+  parseOptions [ ] optionsPath (if optionNames != null then getAttrs optionNames root else root)
@@ -2,6 +2,8 @@ package nixmodule
 
 import (
 	"context"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -12,10 +14,11 @@ import (
 )
 
 type dumpModuleTest struct {
-	name string
-	in   ModuleInput
-	opts DumpModuleOpts
-	want testResult[Module]
+	name     string
+	in       ModuleInput
+	opts     DumpModuleOpts
+	want     testResult[Module]
+	warnings []Warning
 }
 
 var dumpModulePassingTests = []dumpModuleTest{
@@ -64,8 +67,9 @@ var dumpModulePassingTests = []dumpModuleTest{
 			"extras": Module{
 				"enable": BoolOption{
 					OptionDoc: OptionDoc{
-						Example:     true,
-						Default:     false,
+						Example:     valueLiteral(`true`),
+						Default:     valueLiteral(`false`),
+						HasDefault:  true,
 						Description: "Whether to enable magics service extras.",
 					},
 				},
@@ -86,8 +90,576 @@ var dumpModulePassingTests = []dumpModuleTest{
 		}`),
 		want: expectValue(Module{
 			"always-fail": UnspecifiedOption{
-				OptionDoc: OptionDoc{Description: "An option that always fails."},
-				JSON:      jsontext.Value(`{"_option":true,"_type":"always-fail"}`),
+				OptionDoc: OptionDoc{
+					Description: "An option that always fails.",
+					Warnings: []Warning{{
+						Path:   []string{"always-fail"},
+						Type:   "always-fail",
+						Reason: "option type always-fail is not fully implemented",
+					}},
+				},
+				TypeDescription: "This type always fails.",
+				Functor:         TypeFunctor{Name: "always-fail"},
+				JSON:            jsontext.Value(`{"_option":true,"_type":"always-fail"}`),
+			},
+		}),
+		warnings: []Warning{{
+			Path:   []string{"always-fail"},
+			Type:   "always-fail",
+			Reason: "option type always-fail is not fully implemented",
+		}},
+	},
+	{
+		name: "unknown wrapper type",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.wrapped = mkOption {
+				type = mkOptionType {
+					name = "wrapped";
+					description = "wrapped list of string";
+					check = isList;
+					nestedTypes.elemType = types.str;
+					functor = types.defaultFunctor "wrapped" // {
+						payload = { separator = ","; check = isList; };
+					};
+				};
+			};
+		}`),
+		want: expectValue(Module{
+			"wrapped": UnspecifiedOption{
+				OptionDoc: OptionDoc{
+					Warnings: []Warning{{
+						Path:   []string{"wrapped"},
+						Type:   "wrapped",
+						Reason: "option type wrapped is not fully implemented",
+					}},
+				},
+				TypeDescription: "wrapped list of string",
+				Functor: TypeFunctor{
+					Name:    "wrapped",
+					Payload: jsontext.Value(`{"check":null,"separator":","}`),
+				},
+				NestedTypes: map[string]Option{
+					"elemType": StrOption{},
+				},
+				JSON: jsontext.Value(`{"_option":true,"_type":"wrapped"}`),
+			},
+		}),
+		warnings: []Warning{{
+			Path:   []string{"wrapped"},
+			Type:   "wrapped",
+			Reason: "option type wrapped is not fully implemented",
+		}},
+	},
+	{
+		name: "submoduleWith",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.hosts = mkOption {
+				type = types.attrsOf (types.submoduleWith {
+					specialArgs.domain = "example.com";
+					modules = [
+						({ domain, ... }: {
+							options.fqdn = mkOption {
+								type = types.str;
+								default = domain;
+							};
+						})
+					];
+				});
+			};
+		}`),
+		want: expectValue(Module{
+			"hosts": AttrsOfOption{
+				AtrrsOf: SubmoduleOption{
+					Submodule: Module{
+						"fqdn": StrOption{
+							OptionDoc: OptionDoc{Default: valueLiteral(`"example.com"`), HasDefault: true},
+						},
+					},
+				},
+			},
+		}),
+	},
+	{
+		name: "deferred module and functions",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.module = mkOption { type = types.deferredModule; };
+			options.function = mkOption { type = types.functionTo (types.listOf types.str); };
+		}`),
+		want: expectValue(Module{
+			"module": DeferredModuleOption{},
+			"function": FunctionToOption{
+				FunctionTo: ListOfOption{ListOf: StrOption{}},
+			},
+		}),
+	},
+	{
+		name: "enums",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.strings = mkOption { type = types.enum [ "a" "b" ]; };
+			options.ints = mkOption { type = types.enum [ 1 2 3 ]; };
+			options.mixed = mkOption { type = types.enum [ false "auto" null ]; };
+		}`),
+		want: expectValue(Module{
+			"strings": EnumOption{
+				Enum: []jsontext.Value{jsontext.Value(`"a"`), jsontext.Value(`"b"`)},
+			},
+			"ints": EnumOption{
+				Enum: []jsontext.Value{jsontext.Value(`1`), jsontext.Value(`2`), jsontext.Value(`3`)},
+			},
+			"mixed": EnumOption{
+				Enum: []jsontext.Value{jsontext.Value(`false`), jsontext.Value(`"auto"`), jsontext.Value(`null`)},
+			},
+		}),
+	},
+	{
+		name: "literals",
+		in: ModuleExpr(`{ lib, config, ... }: with lib; {
+			options.dir = mkOption {
+				type = types.str;
+				default = "/var/lib/" + config.name;
+				defaultText = literalExpression ''"/var/lib/''${config.name}"'';
+				example = literalMD "A directory within _/srv_.";
+				description = "The directory.";
+			};
+			options.name = mkOption {
+				type = types.str;
+				default = "example";
+				example = { a = [ 1 ]; };
+			};
+		}`),
+		want: expectValue(Module{
+			"dir": StrOption{
+				OptionDoc: OptionDoc{
+					Default:     valueLiteral(`"/var/lib/example"`),
+					HasDefault:  true,
+					DefaultText: Literal{Kind: LiteralExpression, Text: `"/var/lib/${config.name}"`},
+					Example:     Literal{Kind: LiteralMarkdown, Text: "A directory within _/srv_."},
+					Description: "The directory.",
+				},
+			},
+			"name": StrOption{
+				OptionDoc: OptionDoc{
+					Default:    valueLiteral(`"example"`),
+					HasDefault: true,
+					Example:    valueLiteral(`{"a":[1]}`),
+				},
+			},
+		}),
+	},
+	{
+		name: "defaults",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.null = mkOption { type = types.nullOr types.int; default = null; };
+			options.zero = mkOption { type = types.int; default = 0; };
+			options.required = mkOption { type = types.int; };
+		}`),
+		want: expectValue(Module{
+			"null": NullOrOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`null`), HasDefault: true},
+				NullOr:    IntOption{},
+			},
+			"zero": IntOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`0`), HasDefault: true},
+			},
+			"required": IntOption{},
+		}),
+	},
+	{
+		name: "unserializable",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.function = mkOption { type = types.functionTo types.int; default = x: 1; };
+			options.throws = mkOption {
+				type = types.str;
+				default = throw "not defined";
+				defaultText = literalExpression "config.name";
+			};
+			options.nested = mkOption {
+				type = types.attrs;
+				default = { a = 1; };
+				example = { a = [ (throw "nope") ]; };
+			};
+		}`),
+		want: expectValue(Module{
+			"function": FunctionToOption{
+				OptionDoc: OptionDoc{
+					Default:    Literal{Kind: LiteralUnserializable, Text: "lambda"},
+					HasDefault: true,
+				},
+				FunctionTo: IntOption{},
+			},
+			"throws": StrOption{
+				OptionDoc: OptionDoc{
+					HasDefault:  true,
+					DefaultText: Literal{Kind: LiteralExpression, Text: "config.name"},
+				},
+			},
+			"nested": AttrsOption{
+				OptionDoc: OptionDoc{
+					Default:    valueLiteral(`{"a":1}`),
+					HasDefault: true,
+					Example:    Literal{Kind: LiteralUnserializable, Text: "error"},
+				},
+			},
+		}),
+	},
+	{
+		name: "untyped",
+		in: ModuleOptions(`with (import <nixpkgs/lib>); {
+			port = mkOption { default = 8080; description = "The port."; };
+			extra = mkOption { };
+		}`),
+		want: expectValue(Module{
+			"port": UntypedOption{
+				OptionDoc: OptionDoc{
+					Default:     valueLiteral(`8080`),
+					HasDefault:  true,
+					Description: "The port.",
+				},
+			},
+			"extra": UntypedOption{},
+		}),
+	},
+	{
+		name: "untyped evaluated",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.port = mkOption { default = 8080; };
+			options.hosts = lib.options.mkOption { default = [ "a" ]; };
+			options.any = mkOption { type = types.unspecified; };
+		}`),
+		want: expectValue(Module{
+			"port": UntypedOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`8080`), HasDefault: true},
+			},
+			"hosts": UntypedOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`["a"]`), HasDefault: true},
+			},
+			"any": UnspecifiedOption{
+				JSON: jsontext.Value(`{"_option":true,"_type":"unspecified"}`),
+			},
+		}),
+	},
+	{
+		name: "untyped merged",
+		in: ModuleExpr(`[
+			({ lib, ... }: { options.port = lib.mkOption { default = 8080; }; })
+			({ lib, ... }: { options.port = lib.mkOption { type = lib.types.port; }; })
+		]`),
+		want: expectValue(Module{
+			"port": UnsignedInt16Option{
+				OptionDoc: OptionDoc{Default: valueLiteral(`8080`), HasDefault: true},
+			},
+		}),
+	},
+	{
+		name: "visibility",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.hidden = mkOption { type = types.int; visible = false; internal = true; };
+			options.shallow = mkOption { type = types.int; visible = "shallow"; };
+			options.transparent = mkOption { type = types.int; visible = "transparent"; readOnly = true; };
+		}`),
+		want: expectValue(Module{
+			"hidden": IntOption{
+				OptionDoc: OptionDoc{Visible: VisibilityHidden, Internal: true},
+			},
+			"shallow": IntOption{
+				OptionDoc: OptionDoc{Visible: VisibilityShallow},
+			},
+			"transparent": IntOption{
+				OptionDoc: OptionDoc{Visible: VisibilityTransparent, ReadOnly: true},
+			},
+		}),
+	},
+	{
+		name: "imports",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			imports = [
+				{
+					options.imported = mkOption {
+						type = types.str;
+						description = "An imported option.";
+					};
+				}
+			];
+			options.local = mkOption {
+				type = types.int;
+				description = "A local option.";
+			};
+		}`),
+		want: expectValue(Module{
+			"imported": StrOption{
+				OptionDoc: OptionDoc{Description: "An imported option."},
+			},
+			"local": IntOption{
+				OptionDoc: OptionDoc{Description: "A local option."},
+			},
+		}),
+	},
+	{
+		name: "attrset module",
+		in: ModuleExpr(`{
+			options.hello = (import <nixpkgs> { }).lib.mkOption {
+				description = "Hello, world!";
+			};
+		}`),
+		want: expectValue(Module{
+			"hello": UnspecifiedOption{
+				OptionDoc: OptionDoc{Description: "Hello, world!"},
+				JSON:      jsontext.Value(`{"_option":true,"_type":"unspecified"}`),
+			},
+		}),
+	},
+	{
+		name: "reads config",
+		in: ModuleExpr(`{ lib, config, ... }: with lib; {
+			options.name = mkOption {
+				type = types.str;
+				default = "world";
+			};
+			options.greeting = mkOption {
+				type = types.str;
+				default = "Hello, ${config.name}!";
+			};
+		}`),
+		want: expectValue(Module{
+			"name": StrOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`"world"`), HasDefault: true},
+			},
+			"greeting": StrOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`"Hello, world!"`), HasDefault: true},
+			},
+		}),
+	},
+	{
+		name: "module args",
+		in: ModuleExpr(`{ lib, greeting, ... }: with lib; {
+			config._module.args.greeting = "Hello";
+			options.greeting = mkOption {
+				type = types.str;
+				default = greeting;
+			};
+		}`),
+		want: expectValue(Module{
+			"greeting": StrOption{
+				OptionDoc: OptionDoc{Default: valueLiteral(`"Hello"`), HasDefault: true},
+			},
+		}),
+	},
+	{
+		name: "module list",
+		in: ModuleList{
+			ModuleExpr(`{ lib, ... }: with lib; {
+				options.users = mkOption {
+					type = types.attrsOf (types.submodule {
+						options.name = mkOption {
+							type = types.str;
+							description = "The user's name.";
+						};
+					});
+					description = "Users.";
+				};
+			}`),
+			ModuleExpr(`{ lib, ... }: with lib; {
+				options.users = mkOption {
+					type = types.attrsOf (types.submodule {
+						options.shell = mkOption {
+							type = types.str;
+							description = "The user's shell.";
+						};
+					});
+				};
+			}`),
+		},
+		want: expectValue(Module{
+			"users": AttrsOfOption{
+				OptionDoc: OptionDoc{Description: "Users."},
+				AtrrsOf: SubmoduleOption{
+					Submodule: Module{
+						"name": StrOption{
+							OptionDoc: OptionDoc{Description: "The user's name."},
+						},
+						"shell": StrOption{
+							OptionDoc: OptionDoc{Description: "The user's shell."},
+						},
+					},
+				},
+			},
+		}),
+	},
+	{
+		name: "evaluated options",
+		in: ModuleOptions(`((import <nixpkgs/lib>).evalModules {
+			modules = [
+				({ lib, ... }: with lib; {
+					options.hello = mkOption {
+						type = types.str;
+						description = "Hello, world!";
+					};
+				})
+			];
+		}).options`),
+		want: expectValue(Module{
+			"hello": StrOption{
+				OptionDoc: OptionDoc{Description: "Hello, world!"},
+			},
+		}),
+	},
+	{
+		name: "location",
+		in:   ModulePath("testdata/location.nix"),
+		want: expectValue(Module{
+			"hello": StrOption{
+				OptionDoc: OptionDoc{
+					Description: "Hello, world!",
+					Location:    Location{File: mustAbs("testdata/location.nix"), Line: 4, Column: 5},
+					Declarations: []Location{
+						{File: mustAbs("testdata/location.nix"), Line: 4, Column: 5},
+					},
+				},
+			},
+		}),
+	},
+	{
+		name: "relative location",
+		in:   ModulePath("testdata/location.nix"),
+		opts: DumpModuleOpts{
+			DumpModuleWithLocationRoots(`"/nonexistent"`, NixExpr(strconv.Quote(mustAbs(".")))),
+		},
+		want: expectValue(Module{
+			"hello": StrOption{
+				OptionDoc: OptionDoc{
+					Description: "Hello, world!",
+					Location:    Location{File: "testdata/location.nix", Line: 4, Column: 5},
+					Declarations: []Location{
+						{File: "testdata/location.nix", Line: 4, Column: 5},
+					},
+				},
+			},
+		}),
+	},
+	{
+		name: "between bounds",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.int = mkOption { type = types.ints.between (-5) 10; };
+			options.number = mkOption { type = types.numbers.between 0.5 1.5; };
+			options.positive = mkOption { type = types.numbers.positive; };
+		}`),
+		want: expectValue(Module{
+			"int":      IntBetweenOption{Min: -5, Max: 10},
+			"number":   NumberBetweenOption{Min: 0.5, Max: 1.5},
+			"positive": NumberPositiveOption{},
+		}),
+	},
+	{
+		name: "str matching",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.hostname = mkOption { type = types.strMatching "[a-z]+"; };
+		}`),
+		want: expectValue(Module{
+			"hostname": StrMatchingOption{Pattern: "[a-z]+"},
+		}),
+	},
+	{
+		name: "coerced to",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.names = mkOption {
+				type = types.coercedTo types.str (s: [ s ]) (types.listOf types.str);
+			};
+		}`),
+		want: expectValue(Module{
+			"names": CoercedToOption{
+				From: StrOption{},
+				To:   ListOfOption{ListOf: StrOption{}},
+			},
+		}),
+	},
+	{
+		name: "freeform submodule",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.settings = mkOption {
+				type = types.submodule {
+					freeformType = types.attrsOf types.int;
+					options.port = mkOption { type = types.port; };
+				};
+			};
+		}`),
+		want: expectValue(Module{
+			"settings": SubmoduleOption{
+				Submodule: Module{
+					"port": UnsignedInt16Option{},
+				},
+				Freeform: AttrsOfOption{AtrrsOf: IntOption{}},
+			},
+		}),
+	},
+	{
+		name: "freeform warning path",
+		in: ModuleExpr(`{ lib, ... }: with lib; {
+			options.settings = mkOption {
+				type = types.submodule {
+					freeformType = types.attrsOf (mkOptionType { name = "custom"; });
+				};
+			};
+		}`),
+		want: expectValue(Module{
+			"settings": SubmoduleOption{
+				Submodule: Module{},
+				Freeform: AttrsOfOption{
+					AtrrsOf: UnspecifiedOption{
+						OptionDoc: OptionDoc{
+							Warnings: []Warning{{
+								Path:   []string{"settings", "<name>"},
+								Type:   "custom",
+								Reason: "option type custom is not fully implemented",
+							}},
+						},
+						TypeDescription: "custom",
+						Functor:         TypeFunctor{Name: "custom"},
+						JSON:            jsontext.Value(`{"_option":true,"_type":"custom"}`),
+					},
+				},
+			},
+		}),
+		warnings: []Warning{{
+			Path:   []string{"settings", "<name>"},
+			Type:   "custom",
+			Reason: "option type custom is not fully implemented",
+		}},
+	},
+	{
+		name: "formats",
+		in: ModuleExpr(`{ lib, ... }: with lib; let formats = (import <nixpkgs> { }).formats; in {
+			options.json = mkOption { type = (formats.json { }).type; };
+			options.toml = mkOption { type = (formats.toml { }).type; };
+			options.ini = mkOption { type = (formats.ini { }).type; };
+			options.keyValue = mkOption { type = (formats.keyValue { }).type; };
+		}`),
+		want: expectValue(Module{
+			"json":     FormatOption{Format: FormatJSON},
+			"toml":     FormatOption{Format: FormatTOML},
+			"ini":      FormatOption{Format: FormatINI},
+			"keyValue": FormatOption{Format: FormatKeyValue},
+		}),
+	},
+	{
+		name: "recursive",
+		in: ModuleExpr(`{ lib, ... }: with lib; let
+			tree = types.attrsOf (types.either types.str (types.lazyAttrsOf tree));
+			list = types.listOf (types.nullOr list);
+		in {
+			options.tree = mkOption { type = tree; };
+			options.list = mkOption { type = list; };
+		}`),
+		want: expectValue(Module{
+			"tree": AttrsOfOption{
+				AtrrsOf: EitherOption{
+					Either: []Option{
+						StrOption{},
+						AttrsOfOption{AtrrsOf: RefOption{Up: 3}},
+					},
+				},
+			},
+			"list": ListOfOption{
+				ListOf: NullOrOption{NullOr: RefOption{Up: 2}},
 			},
 		}),
 	},
@@ -96,7 +668,20 @@ var dumpModulePassingTests = []dumpModuleTest{
 var dumpModuleFailingTests = []dumpModuleTest{
 	{
 		name: "invalid module",
-		in:   ModuleExpr(`{ ... }: { }`),
+		in:   ModuleExpr(`{ ... }: { options = 42; }`),
+		want: expectAnyError[Module](),
+	},
+	{
+		name: "missing import",
+		in:   ModuleExpr(`{ ... }: { imports = [ /nonexistent/module.nix ]; }`),
+		want: expectAnyError[Module](),
+	},
+	{
+		name: "invalid expression in list",
+		in: ModuleList{
+			ModuleExpr(`{ ... }: { }`),
+			ModuleExpr(`{`),
+		},
 		want: expectAnyError[Module](),
 	},
 	{
@@ -130,8 +715,13 @@ func TestDumpModule(t *testing.T) {
 				var err error
 
 				t.Run("expect", func(t *testing.T) {
-					module, err = DumpModule(ctx, test.in, test.opts...)
+					var warnings []Warning
+					module, warnings, err = DumpModule(ctx, test.in, test.opts...)
 					test.want.expect(t, module, err)
+
+					if diff := cmp.Diff(test.warnings, warnings); diff != "" {
+						t.Fatalf("unexpected warnings (-want +got):\n%s", diff)
+					}
 				})
 
 				if test.want.expectingError() {
@@ -157,6 +747,14 @@ func TestDumpModule(t *testing.T) {
 	}
 }
 
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		panic(err)
+	}
+	return abs
+}
+
 func canonicalizeJSON[T ~[]byte](t *testing.T, in T) []byte {
 	// Force unmarshaling to `any` to erase all orderings of object keys.
 	// This way, Deterministic will sort the keys in a consistent order.
@@ -177,6 +775,10 @@ type testResult[T any] struct {
 	Error error
 }
 
+func valueLiteral(value string) Literal {
+	return Literal{Kind: LiteralValue, Value: jsontext.Value(value)}
+}
+
 func expectValue[T any](v T) testResult[T] {
 	return testResult[T]{v, nil}
 }
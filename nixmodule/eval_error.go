@@ -0,0 +1,173 @@
+package nixmodule
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EvalError is an error that occurred while Nix was evaluating an expression.
+// It is parsed from the error output of Nix, so that callers can inspect it
+// using [errors.As].
+type EvalError struct {
+	// Message is the error message without the `error:` prefix.
+	// It may span multiple lines.
+	Message string
+	// Location is where the error occurred, if known.
+	Location Location
+	// Trace is the list of frames that led to the error, outermost first.
+	// It is only complete if Nix was run with `--show-trace`.
+	Trace []EvalTraceFrame
+	// OptionPath is the path of the option that was being evaluated when the
+	// error occurred, if known.
+	OptionPath []string
+	// Output is the raw error output of Nix.
+	Output string
+}
+
+// EvalTraceFrame is a single frame of an [EvalError]'s trace.
+type EvalTraceFrame struct {
+	// Message describes what Nix was doing, such as
+	// "while evaluating the attribute 'foo'".
+	Message string
+	// Location is where the frame is, if known.
+	Location Location
+}
+
+// Error implements the error interface.
+func (e *EvalError) Error() string {
+	var b strings.Builder
+	b.WriteString("nix: ")
+	if len(e.OptionPath) > 0 {
+		b.WriteString("option `")
+		b.WriteString(strings.Join(e.OptionPath, "."))
+		b.WriteString("`: ")
+	}
+	b.WriteString(e.Message)
+	if e.Location.File != "" {
+		b.WriteString(" (at ")
+		b.WriteString(e.Location.String())
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+var (
+	nixAnsiRe       = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	nixErrorLineRe  = regexp.MustCompile(`^error:\s*(.*)$`)
+	nixLocationRe   = regexp.MustCompile(`^at (.+):(\d+):(\d+):?(?:\s+\(source not available\))?$`)
+	nixSnippetRe    = regexp.MustCompile(`^\d*\s*\|`)
+	nixOptionPathRe = regexp.MustCompile("while (?:evaluating|dumping) the option `(.+)'")
+)
+
+// parseEvalError parses the error output of Nix into an [EvalError].
+// It returns nil if the output doesn't contain an error.
+func parseEvalError(output string) *EvalError {
+	output = nixAnsiRe.ReplaceAllString(output, "")
+
+	var (
+		evalErr *EvalError
+		// message and location point to the item that is being parsed, which
+		// is either a trace frame or the final error.
+		message  *string
+		location *Location
+		// continued is true if the next text line continues the message.
+		continued bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if evalErr == nil {
+			// Skip everything before the error, such as warnings and traces.
+			m := nixErrorLineRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			evalErr = &EvalError{Message: m[1], Output: output}
+			message = &evalErr.Message
+			location = &evalErr.Location
+			continued = m[1] != ""
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continued = false
+		case nixSnippetRe.MatchString(line):
+			// Code snippets are reconstructed from the location instead.
+			continued = false
+		case strings.HasPrefix(line, "(stack trace truncated"):
+			continued = false
+		case strings.HasPrefix(line, "… "), strings.HasPrefix(line, "... "):
+			line = strings.TrimPrefix(line, "… ")
+			line = strings.TrimPrefix(line, "... ")
+			evalErr.Trace = append(evalErr.Trace, EvalTraceFrame{Message: line})
+			frame := &evalErr.Trace[len(evalErr.Trace)-1]
+			message = &frame.Message
+			location = &frame.Location
+			continued = true
+		case strings.HasPrefix(line, "error: "):
+			// The final error comes after the trace.
+			evalErr.Message = strings.TrimPrefix(line, "error: ")
+			message = &evalErr.Message
+			location = &evalErr.Location
+			continued = true
+		case nixLocationRe.MatchString(line):
+			m := nixLocationRe.FindStringSubmatch(line)
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			*location = Location{File: m[1], Line: lineNo, Column: column}
+			continued = false
+		case continued:
+			if *message == "" {
+				*message = line
+			} else {
+				*message += "\n" + line
+			}
+		}
+	}
+
+	if evalErr == nil {
+		return nil
+	}
+
+	// The innermost option is the most precise one.
+	for _, frame := range evalErr.Trace {
+		if m := nixOptionPathRe.FindStringSubmatch(frame.Message); m != nil {
+			evalErr.OptionPath = splitOptionPath(m[1])
+		}
+	}
+
+	return evalErr
+}
+
+// splitOptionPath splits an option path as formatted by Nixpkgs'
+// lib.showOption, which quotes names that contain dots.
+func splitOptionPath(s string) []string {
+	var path []string
+	var b strings.Builder
+	var quoted bool
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && quoted && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == '.' && !quoted:
+			path = append(path, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return append(path, b.String())
+}
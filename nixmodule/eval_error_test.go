@@ -0,0 +1,93 @@
+package nixmodule
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseEvalError(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *EvalError
+	}{
+		{
+			name:   "no error",
+			output: "warning: unknown setting 'foo'\n",
+			want:   nil,
+		},
+		{
+			name: "single line",
+			output: `error: undefined variable 'foo'
+
+       at «string»:1:1:
+
+            1| foo
+             | ^
+`,
+			want: &EvalError{
+				Message:  "undefined variable 'foo'",
+				Location: Location{File: "«string»", Line: 1, Column: 1},
+			},
+		},
+		{
+			name: "trace",
+			output: `trace: hello
+error:
+       … while dumping the option ` + "`services.magics'" + `
+
+       … while evaluating the option ` + "`services.magics.\"port.number\"'" + `:
+
+       … while evaluating definitions from ` + "`/etc/nixos/magics.nix'" + `:
+
+       … while evaluating the attribute 'value'
+         at /nix/store/xxx-source/lib/modules.nix:809:9:
+
+          808|     in warnDeprecation opt //
+          809|       { value = addErrorContext "while evaluating the option ` + "`${showOption loc}':\" value;" + `
+             |         ^
+          810|         inherit (res.defsFinal') highestPrio;
+
+       (stack trace truncated; use '--show-trace' to show the full trace)
+
+       error: A definition for option ` + "`services.magics.\"port.number\"'" + ` is not of type ` + "`16 bit unsigned integer'" + `.
+       Definition values:
+       - In ` + "`/etc/nixos/magics.nix'" + `: "80"
+
+       at /etc/nixos/magics.nix:3:5:
+
+            2| {
+            3|   services.magics."port.number" = "80";
+             |     ^
+            4| }
+`,
+			want: &EvalError{
+				Message: "A definition for option `services.magics.\"port.number\"' is not of type `16 bit unsigned integer'.\n" +
+					"Definition values:\n" +
+					"- In `/etc/nixos/magics.nix': \"80\"",
+				Location: Location{File: "/etc/nixos/magics.nix", Line: 3, Column: 5},
+				Trace: []EvalTraceFrame{
+					{Message: "while dumping the option `services.magics'"},
+					{Message: "while evaluating the option `services.magics.\"port.number\"':"},
+					{Message: "while evaluating definitions from `/etc/nixos/magics.nix':"},
+					{
+						Message:  "while evaluating the attribute 'value'",
+						Location: Location{File: "/nix/store/xxx-source/lib/modules.nix", Line: 809, Column: 9},
+					},
+				},
+				OptionPath: []string{"services", "magics", "port.number"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseEvalError(test.output)
+			if diff := cmp.Diff(test.want, got, cmpopts.IgnoreFields(EvalError{}, "Output")); diff != "" {
+				t.Fatalf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
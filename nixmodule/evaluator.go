@@ -0,0 +1,182 @@
+package nixmodule
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// Evaluator evaluates Nix expressions. It allows [DumpModule] to be used with
+// different Nix implementations, or without Nix at all.
+type Evaluator interface {
+	// Parse checks if the expression is valid without evaluating it.
+	Parse(ctx context.Context, expr NixExpr) error
+	// Eval strictly evaluates the request and returns the result as JSON.
+	// Nix errors should be returned as [*EvalError] if possible.
+	Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error)
+}
+
+// EvalRequest is a request to evaluate a Nix expression.
+type EvalRequest struct {
+	// Expr is the expression to evaluate.
+	Expr NixExpr
+	// Args are the arguments to call Expr with, like `nix-instantiate --arg`.
+	// Expr must be a function that takes an attribute set if Args is not
+	// empty.
+	Args map[string]NixExpr
+	// Stderr, if not nil, receives the diagnostic output of Nix, such as
+	// warnings and traces.
+	Stderr io.Writer
+}
+
+// CallExpr returns Expr called with Args as a single Nix expression.
+func (r EvalRequest) CallExpr() NixExpr {
+	if len(r.Args) == 0 {
+		return r.Expr
+	}
+
+	// Put closing parentheses on a new line in case the expressions end with
+	// a comment.
+	var b strings.Builder
+	fmt.Fprintf(&b, "(%s\n) { ", r.Expr)
+	for _, k := range slices.Sorted(maps.Keys(r.Args)) {
+		fmt.Fprintf(&b, "%q = (%s\n); ", k, r.Args[k])
+	}
+	b.WriteString("}")
+	return NixExpr(b.String())
+}
+
+// DefaultEvaluator is the [Evaluator] used when none is given.
+var DefaultEvaluator Evaluator = NixInstantiate{}
+
+// NixInstantiate is an [Evaluator] that uses `nix-instantiate`. It works
+// with both Nix and Lix.
+type NixInstantiate struct {
+	// Path is the path to the nix-instantiate binary.
+	// By default, it's looked up in $PATH.
+	Path string
+	// ExtraArgs are additional arguments given to every invocation.
+	ExtraArgs []string
+}
+
+var _ Evaluator = NixInstantiate{}
+
+func (n NixInstantiate) path() string {
+	if n.Path != "" {
+		return n.Path
+	}
+	return "nix-instantiate"
+}
+
+// Parse implements [Evaluator].
+func (n NixInstantiate) Parse(ctx context.Context, expr NixExpr) error {
+	args := slices.Concat(n.ExtraArgs, []string{"--parse", "--expr", string(expr)})
+	_, err := runNix(ctx, n.path(), args, nil)
+	return err
+}
+
+// Eval implements [Evaluator].
+func (n NixInstantiate) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	args := slices.Concat(n.ExtraArgs, []string{"--eval", "--strict", "--json", "--show-trace"})
+	for _, k := range slices.Sorted(maps.Keys(req.Args)) {
+		args = append(args, "--arg", k, string(req.Args[k]))
+	}
+	args = append(args, "--expr", string(req.Expr))
+	return runNix(ctx, n.path(), args, req.Stderr)
+}
+
+// NixEval is an [Evaluator] that uses the flake-native `nix eval --json`.
+// Evaluation is impure, so that `<nixpkgs>`, `builtins.getFlake` and absolute
+// paths work just like with [NixInstantiate].
+type NixEval struct {
+	// Path is the path to the nix binary.
+	// By default, it's looked up in $PATH.
+	Path string
+	// ExtraArgs are additional arguments given to every invocation.
+	ExtraArgs []string
+}
+
+var _ Evaluator = NixEval{}
+
+func (n NixEval) path() string {
+	if n.Path != "" {
+		return n.Path
+	}
+	return "nix"
+}
+
+func (n NixEval) args(expr NixExpr) []string {
+	return slices.Concat(
+		[]string{"eval", "--extra-experimental-features", "nix-command flakes"},
+		n.ExtraArgs,
+		[]string{"--impure", "--json", "--show-trace", "--expr", string(expr)},
+	)
+}
+
+// Parse implements [Evaluator].
+func (n NixEval) Parse(ctx context.Context, expr NixExpr) error {
+	// nix eval has no parse-only mode. Binding the expression without using
+	// it parses it, including checking for undefined variables, without
+	// evaluating it.
+	_, err := runNix(ctx, n.path(), n.args(NixExpr(fmt.Sprintf("let _ = (%s\n); in null", expr))), nil)
+	return err
+}
+
+// Eval implements [Evaluator].
+func (n NixEval) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	return runNix(ctx, n.path(), n.args(req.CallExpr()), req.Stderr)
+}
+
+// runNix runs a Nix command and returns its standard output. The standard
+// error is always captured so that errors can be parsed, even if it's also
+// copied to stderr.
+func runNix(ctx context.Context, name string, args []string, stderr io.Writer) ([]byte, error) {
+	var stdout bytes.Buffer
+	var stderrBuf strings.Builder
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderrBuf
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, &stderrBuf)
+	}
+
+	slog.DebugContext(ctx,
+		"running nix",
+		"name", name,
+		"args", slices.DeleteFunc(slices.Clone(args), isLongArg))
+
+	if err := cmd.Run(); err != nil {
+		return nil, nixCommandError(name, err, stderrBuf.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// isLongArg returns true for arguments that are too long to be logged, such
+// as the embedded dump_module.nix.
+func isLongArg(arg string) bool {
+	return strings.Count(arg, "\n") > 10
+}
+
+// nixCommandError converts the error of a failed Nix command into an
+// [EvalError] if possible.
+func nixCommandError(name string, err error, stderr string) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if evalErr := parseEvalError(stderr); evalErr != nil {
+		return evalErr
+	}
+	return fmt.Errorf("%s: %s", name, strings.TrimSpace(stderr))
+}
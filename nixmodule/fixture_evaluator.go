@@ -0,0 +1,88 @@
+package nixmodule
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// FixtureEvaluator is an [Evaluator] that serves recorded JSON results instead
+// of running Nix. It allows code built on [DumpModule] to be tested on machines
+// without Nix.
+//
+// Each result is stored in Dir as a file named after [FixtureKey] of its
+// request. If Record is set, requests without a fixture are evaluated using
+// Record and their results are written to Dir.
+type FixtureEvaluator struct {
+	// Dir is the directory containing the fixtures.
+	Dir string
+	// Record, if not nil, is used to evaluate and record missing fixtures.
+	Record Evaluator
+}
+
+var _ Evaluator = FixtureEvaluator{}
+
+// Parse implements [Evaluator]. Expressions are only parsed if Record is set,
+// otherwise they are assumed to be valid.
+func (f FixtureEvaluator) Parse(ctx context.Context, expr NixExpr) error {
+	if f.Record == nil {
+		return nil
+	}
+	return f.Record.Parse(ctx, expr)
+}
+
+// Eval implements [Evaluator].
+func (f FixtureEvaluator) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	path := filepath.Join(f.Dir, FixtureKey(req)+".json")
+
+	b, err := os.ReadFile(path)
+	if err == nil {
+		return jsontext.Value(b), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) || f.Record == nil {
+		return nil, fmt.Errorf("fixture: %w", err)
+	}
+
+	v, err := f.Record.Eval(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("fixture: %w", err)
+	}
+	if err := os.WriteFile(path, v, 0o644); err != nil {
+		return nil, fmt.Errorf("fixture: %w", err)
+	}
+
+	return v, nil
+}
+
+// FixtureKey returns the key that [FixtureEvaluator] stores the result of the
+// request under. Occurrences of the current working directory are replaced
+// with ".", so that fixtures of [ModulePath]s can be shared across checkouts.
+func FixtureKey(req EvalRequest) string {
+	replace := func(expr NixExpr) string { return string(expr) }
+	if wd, err := os.Getwd(); err == nil {
+		replace = func(expr NixExpr) string {
+			return strings.ReplaceAll(string(expr), wd, ".")
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", replace(req.Expr))
+	for _, k := range slices.Sorted(maps.Keys(req.Args)) {
+		fmt.Fprintf(h, "%q=%q\n", k, replace(req.Args[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,65 @@
+package nixmodule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/go-cmp/cmp"
+)
+
+// stubEvaluator always returns the same value and counts its evaluations.
+type stubEvaluator struct {
+	value jsontext.Value
+	evals *int
+}
+
+func (e stubEvaluator) Parse(ctx context.Context, expr NixExpr) error { return nil }
+
+func (e stubEvaluator) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	*e.evals++
+	return e.value, nil
+}
+
+func TestFixtureEvaluator(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var evals int
+	stub := stubEvaluator{
+		value: jsontext.Value(`{"hello":{"_option":true,"_type":"str","description":"Hello, world!"}}`),
+		evals: &evals,
+	}
+
+	want := Module{
+		"hello": StrOption{
+			OptionDoc: OptionDoc{Description: "Hello, world!"},
+		},
+	}
+
+	in := ModuleExpr(`{ lib, ... }: { options.hello = lib.mkOption { type = lib.types.str; }; }`)
+
+	t.Run("record", func(t *testing.T) {
+		m, _, err := DumpModule(ctx, in, DumpModuleWithEvaluator(FixtureEvaluator{Dir: dir, Record: stub}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, evals, "expected the request to be recorded")
+		if diff := cmp.Diff(want, m); diff != "" {
+			t.Fatalf("unexpected module (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		m, _, err := DumpModule(ctx, in, DumpModuleWithEvaluator(FixtureEvaluator{Dir: dir}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, evals, "expected the fixture to be used")
+		if diff := cmp.Diff(want, m); diff != "" {
+			t.Fatalf("unexpected module (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, _, err := DumpModule(ctx, ModuleExpr(`{ }`), DumpModuleWithEvaluator(FixtureEvaluator{Dir: dir}))
+		assert.Error(t, err)
+	})
+}
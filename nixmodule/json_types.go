@@ -1,7 +1,9 @@
 package nixmodule
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/go-json-experiment/json/jsontext"
 )
@@ -53,6 +55,9 @@ var optionMap = func(preps ...optionPrepValue) map[string]reflect.Type {
 	prepOption[UnsignedInt16Option](),
 	prepOption[UnsignedInt32Option](),
 	prepOption[UnsignedIntOption](),
+	prepOption[NumberBetweenOption](),
+	prepOption[NumberPositiveOption](),
+	prepOption[NumberNonnegativeOption](),
 	prepOption[PathOption](),
 	prepOption[BoolOption](),
 	prepOption[FloatOption](),
@@ -62,53 +67,124 @@ var optionMap = func(preps ...optionPrepValue) map[string]reflect.Type {
 	prepOption[UnspecifiedOption](),
 	prepOption[EnumOption](),
 	prepOption[SeparatedString](),
+	prepOption[StrMatchingOption](),
 	prepOption[UniqueOption](),
 	prepOption[EitherOption](),
 	prepOption[NullOrOption](),
 	prepOption[ListOfOption](),
 	prepOption[AttrsOfOption](),
 	prepOption[SubmoduleOption](),
+	prepOption[CoercedToOption](),
+	prepOption[FormatOption](),
+	prepOption[RefOption](),
+	prepOption[DeferredModuleOption](),
+	prepOption[FunctionToOption](),
+	prepOption[UntypedOption](),
 )
 
-func (StrOption) Type() string           { return "str" }
-func (IntOption) Type() string           { return "int" }
-func (IntBetweenOption) Type() string    { return "intBetween" }
-func (PositiveIntOption) Type() string   { return "positiveInt" }
-func (SignedInt8Option) Type() string    { return "signedInt8" }
-func (SignedInt16Option) Type() string   { return "signedInt16" }
-func (SignedInt32Option) Type() string   { return "signedInt32" }
-func (UnsignedInt8Option) Type() string  { return "unsignedInt8" }
-func (UnsignedInt16Option) Type() string { return "unsignedInt16" }
-func (UnsignedInt32Option) Type() string { return "unsignedInt32" }
-func (UnsignedIntOption) Type() string   { return "unsignedInt" }
-func (PathOption) Type() string          { return "path" }
-func (BoolOption) Type() string          { return "bool" }
-func (FloatOption) Type() string         { return "float" }
-func (AttrsOption) Type() string         { return "attrs" }
-func (PackageOption) Type() string       { return "package" }
-func (AnythingOption) Type() string      { return "anything" }
-func (UnspecifiedOption) Type() string   { return "unspecified" }
-func (EnumOption) Type() string          { return "enum" }
-func (SeparatedString) Type() string     { return "separatedString" }
-func (UniqueOption) Type() string        { return "unique" }
-func (EitherOption) Type() string        { return "either" }
-func (NullOrOption) Type() string        { return "nullOr" }
-func (ListOfOption) Type() string        { return "listOf" }
-func (AttrsOfOption) Type() string       { return "attrsOf" }
-func (SubmoduleOption) Type() string     { return "submodule" }
+func (StrOption) Type() string               { return "str" }
+func (IntOption) Type() string               { return "int" }
+func (IntBetweenOption) Type() string        { return "intBetween" }
+func (PositiveIntOption) Type() string       { return "positiveInt" }
+func (SignedInt8Option) Type() string        { return "signedInt8" }
+func (SignedInt16Option) Type() string       { return "signedInt16" }
+func (SignedInt32Option) Type() string       { return "signedInt32" }
+func (UnsignedInt8Option) Type() string      { return "unsignedInt8" }
+func (UnsignedInt16Option) Type() string     { return "unsignedInt16" }
+func (UnsignedInt32Option) Type() string     { return "unsignedInt32" }
+func (UnsignedIntOption) Type() string       { return "unsignedInt" }
+func (NumberBetweenOption) Type() string     { return "numberBetween" }
+func (NumberPositiveOption) Type() string    { return "numberPositive" }
+func (NumberNonnegativeOption) Type() string { return "numberNonnegative" }
+func (PathOption) Type() string              { return "path" }
+func (BoolOption) Type() string              { return "bool" }
+func (FloatOption) Type() string             { return "float" }
+func (AttrsOption) Type() string             { return "attrs" }
+func (PackageOption) Type() string           { return "package" }
+func (AnythingOption) Type() string          { return "anything" }
+func (UnspecifiedOption) Type() string       { return "unspecified" }
+func (EnumOption) Type() string              { return "enum" }
+func (SeparatedString) Type() string         { return "separatedString" }
+func (StrMatchingOption) Type() string       { return "strMatching" }
+func (UniqueOption) Type() string            { return "unique" }
+func (EitherOption) Type() string            { return "either" }
+func (NullOrOption) Type() string            { return "nullOr" }
+func (ListOfOption) Type() string            { return "listOf" }
+func (AttrsOfOption) Type() string           { return "attrsOf" }
+func (SubmoduleOption) Type() string         { return "submodule" }
+func (CoercedToOption) Type() string         { return "coercedTo" }
+func (FormatOption) Type() string            { return "format" }
+func (RefOption) Type() string               { return "ref" }
+func (DeferredModuleOption) Type() string    { return "deferredModule" }
+func (FunctionToOption) Type() string        { return "functionTo" }
+func (UntypedOption) Type() string           { return "untyped" }
 
 // OptionDoc represents the documentation for a Nix option.
 // It is extracted directly from mkOption.
 // All fields are optional and may be empty.
 type OptionDoc struct {
-	Example          any    `json:"example,omitzero"`
-	Default          any    `json:"default,omitzero"`
-	DefaultText      string `json:"defaultText,omitzero"`
-	Description      string `json:"description,omitzero"`
-	DescriptionClass string `json:"descriptionClass,omitzero"`
-	Visible          bool   `json:"visible,omitzero"`
-	Internal         bool   `json:"internal,omitzero"`
-	ReadOnly         bool   `json:"readOnly,omitzero"`
+	Example Literal `json:"example,omitzero"`
+	Default Literal `json:"default,omitzero"`
+	// HasDefault is true if the option has a default value, even if Default
+	// couldn't be dumped. Options without a default value must be defined.
+	HasDefault bool `json:"hasDefault,omitzero"`
+	// DefaultText documents the default value in place of Default, usually
+	// because Default depends on other options.
+	DefaultText      Literal `json:"defaultText,omitzero"`
+	Description      string  `json:"description,omitzero"`
+	DescriptionClass string  `json:"descriptionClass,omitzero"`
+	// Visible is whether the option and its sub-options are shown in the
+	// documentation. It's empty if the option doesn't set it.
+	Visible  Visibility `json:"visible,omitzero"`
+	Internal bool       `json:"internal,omitzero"`
+	ReadOnly bool       `json:"readOnly,omitzero"`
+	// Location is where the option is declared. It is only set for options
+	// declared outside of Nixpkgs. Like in Declarations, its file is relative
+	// if it's within one of the roots given to [DumpModuleWithLocationRoots].
+	Location Location `json:"location,omitzero"`
+	// Declarations is the list of all places where the option is declared,
+	// including Nixpkgs.
+	Declarations []Location `json:"declarations,omitzero"`
+	// Warnings is the list of problems found while dumping this option.
+	Warnings []Warning `json:"_warnings,omitzero"`
+}
+
+// Warning describes a problem found while dumping an option. The option is
+// still dumped, but it may be less precise than it should be, e.g. an
+// [UnspecifiedOption] instead of a more specific type.
+type Warning struct {
+	// Path is the path of the affected option.
+	Path []string `json:"path"`
+	// Type is the name of the Nix type that caused the warning.
+	Type string `json:"type"`
+	// Reason describes the problem.
+	Reason string `json:"reason"`
+}
+
+// String formats the warning as `path: reason`.
+func (w Warning) String() string {
+	return strings.Join(w.Path, ".") + ": " + w.Reason
+}
+
+// Location describes a position within a Nix file.
+// Line and Column may be zero if the position is unknown.
+type Location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitzero"`
+	Column int    `json:"column,omitzero"`
+}
+
+// String formats the location as `file:line:column`, omitting the parts that
+// are unknown.
+func (l Location) String() string {
+	switch {
+	case l.Line == 0:
+		return l.File
+	case l.Column == 0:
+		return fmt.Sprintf("%s:%d", l.File, l.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+	}
 }
 
 // Doc returns itself.
@@ -166,6 +242,10 @@ type IntOption struct {
 // Equivalent Nix type: types.ints.between
 type IntBetweenOption struct {
 	OptionDoc
+	// Min is the lowest allowed value, inclusive.
+	Min int64 `json:"min"`
+	// Max is the highest allowed value, inclusive.
+	Max int64 `json:"max"`
 }
 
 // PositiveIntOption is a Nix positive integer option.
@@ -224,6 +304,34 @@ type UnsignedIntOption struct {
 	OptionDoc
 }
 
+// NumberBetweenOption is a Nix number between option.
+// Its values may be either integers or floats.
+//
+// Equivalent Nix type: types.numbers.between
+type NumberBetweenOption struct {
+	OptionDoc
+	// Min is the lowest allowed value, inclusive.
+	Min float64 `json:"min"`
+	// Max is the highest allowed value, inclusive.
+	Max float64 `json:"max"`
+}
+
+// NumberPositiveOption is a Nix positive number option.
+// Its values may be either integers or floats.
+//
+// Equivalent Nix type: types.numbers.positive
+type NumberPositiveOption struct {
+	OptionDoc
+}
+
+// NumberNonnegativeOption is a Nix nonnegative number option.
+// Its values may be either integers or floats.
+//
+// Equivalent Nix type: types.numbers.nonnegative
+type NumberNonnegativeOption struct {
+	OptionDoc
+}
+
 // PathOption is a Nix path option.
 //
 // Equivalent Nix type: types.path
@@ -275,8 +383,9 @@ type AnythingOption struct {
 // Equivalent Nix type: types.enum
 type EnumOption struct {
 	OptionDoc
-	// Enum is the list of possible values.
-	Enum []string `json:"enum,string"`
+	// Enum is the list of possible values. Values are usually strings, but
+	// they may also be integers, floats, booleans or null.
+	Enum []jsontext.Value `json:"enum"`
 }
 
 // SeparatedString is a Nix separated string option.
@@ -288,6 +397,16 @@ type SeparatedString struct {
 	Separator string `json:"separator"`
 }
 
+// StrMatchingOption is a Nix string option whose values must match a pattern.
+//
+// Equivalent Nix type: types.strMatching
+type StrMatchingOption struct {
+	OptionDoc
+	// Pattern is the POSIX extended regular expression that the whole string
+	// must match, as used by builtins.match.
+	Pattern string `json:"pattern"`
+}
+
 // UniqueOption is a Nix unique option.
 //
 // Equivalent Nix type: types.uniq and types.unique
@@ -336,10 +455,103 @@ type AttrsOfOption struct {
 // SubmoduleOption describes a nested Nix module.
 // It is functionally equivalent to a [Module] but is used within.
 //
-// Equivalent Nix type: types.submodule
+// Equivalent Nix type: types.submodule or types.submoduleWith
 type SubmoduleOption struct {
 	OptionDoc
 	Submodule Module `json:"submodule"`
+	// Freeform is the type of the values of undeclared attributes, if the
+	// submodule has a freeformType. It is usually an [AttrsOfOption].
+	Freeform Option `json:"freeform,omitzero"`
+}
+
+// CoercedToOption is a Nix option whose values may be given as either of two
+// types. Values of the From type are converted to the To type by a Nix
+// function, so evaluated values are always of the To type.
+//
+// Equivalent Nix type: types.coercedTo
+type CoercedToOption struct {
+	OptionDoc
+	// From is the type that values are coerced from.
+	From Option `json:"from"`
+	// To is the final type of the values.
+	To Option `json:"to"`
+}
+
+// DeferredModuleOption is a Nix option whose values are modules, which are
+// only evaluated later by whatever imports them. Modules may be functions, so
+// their values usually can't be represented as JSON.
+//
+// Equivalent Nix type: types.deferredModule
+type DeferredModuleOption struct {
+	OptionDoc
+}
+
+// FunctionToOption is a Nix option whose values are functions. Functions
+// can't be represented as JSON, so only the type they return is known.
+//
+// Equivalent Nix type: types.functionTo
+type FunctionToOption struct {
+	OptionDoc
+	// FunctionTo is the type of the values returned by the functions.
+	FunctionTo Option `json:"functionTo"`
+}
+
+// UntypedOption is a Nix option declared without a type, which older modules
+// sometimes do. Its values may be anything, but their type is usually evident
+// from the default value.
+//
+// lib.evalModules gives untyped options types.unspecified, so they can only
+// be told apart if they're declared using the mkOption of the lib module
+// argument, or if the option set wasn't evaluated by lib.evalModules at all.
+// Options declared using the mkOption of pkgs.lib or of an imported lib are
+// dumped as an [UnspecifiedOption]. An option declared by several modules is
+// only untyped if none of them gives it a type.
+type UntypedOption struct {
+	OptionDoc
+}
+
+// FormatOption is an option whose type comes from one of the pkgs.formats
+// generators, which are usually used for configuration files.
+//
+// Equivalent Nix type: (pkgs.formats.<format> { }).type
+type FormatOption struct {
+	OptionDoc
+	// Format is the name of the generator, which is one of the Format
+	// constants.
+	Format Format `json:"format"`
+}
+
+// Format is the name of a pkgs.formats generator.
+type Format string
+
+const (
+	// FormatJSON is pkgs.formats.json. Values may be anything JSON can
+	// represent.
+	FormatJSON Format = "json"
+	// FormatYAML is pkgs.formats.yaml and its versioned variants. Values may
+	// be anything JSON can represent.
+	FormatYAML Format = "yaml"
+	// FormatTOML is pkgs.formats.toml. Values may be anything JSON can
+	// represent except for null.
+	FormatTOML Format = "toml"
+	// FormatINI is pkgs.formats.ini. Values are attribute sets of sections,
+	// which are attribute sets of atoms.
+	FormatINI Format = "ini"
+	// FormatKeyValue is pkgs.formats.keyValue. Values are attribute sets of
+	// atoms.
+	FormatKeyValue Format = "keyValue"
+)
+
+// RefOption refers to one of the types that it is nested within. It is used
+// instead of the type itself for recursive types, which would otherwise never
+// end, such as `attrsOf (either str self)`.
+type RefOption struct {
+	OptionDoc
+	// Up is how many types up the referred type is. 1 is the type that
+	// directly contains the RefOption, 2 is the one above it, and so on.
+	// Every option counts, except for [Module]s, which are plain attribute
+	// sets of options.
+	Up int `json:"up"`
 }
 
 // UnspecifiedOption is a Nix unspecified option.
@@ -348,9 +560,27 @@ type SubmoduleOption struct {
 // Equivalent Nix type: types.unspecified
 type UnspecifiedOption struct {
 	OptionDoc
+	// TypeDescription is the description of the unknown type, such as
+	// "non-empty list of string".
+	TypeDescription string `json:"typeDescription,omitzero"`
+	// Functor describes how the unknown type was constructed.
+	Functor TypeFunctor `json:"functor,omitzero"`
+	// NestedTypes are the types that the unknown type is made of, such as
+	// elemType for types that wrap another type.
+	NestedTypes map[string]Option `json:"nestedTypes,omitzero"`
 	// JSON is the raw JSON value of the option.
 	// It is used when the type is unknown or unsupported.
 	JSON jsontext.Value `json:",unknown"`
 }
 
+// TypeFunctor is the functor of a Nix type, which describes how the type was
+// constructed.
+type TypeFunctor struct {
+	// Name is the name of the type constructor.
+	Name string `json:"name"`
+	// Payload is the argument given to the type constructor. Values that
+	// can't be represented as JSON, such as functions and types, are null.
+	Payload jsontext.Value `json:"payload,omitzero"`
+}
+
 func (o UnspecifiedOption) isUnspecifiedOption() {}
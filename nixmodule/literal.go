@@ -0,0 +1,116 @@
+package nixmodule
+
+import (
+	"bytes"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// Literal is a value within the documentation of an option, such as its
+// default or example. Nixpkgs modules often give these as Nix expressions or
+// Markdown text using lib.literalExpression or lib.literalMD instead of as
+// plain values.
+type Literal struct {
+	// Kind is the kind of the literal.
+	Kind LiteralKind
+	// Text is the Nix expression or the Markdown text, or why the value
+	// couldn't be dumped for [LiteralUnserializable]. It is empty for plain
+	// values.
+	Text string
+	// Value is the plain value as compact JSON. It is empty for Nix
+	// expressions and Markdown text.
+	Value jsontext.Value
+}
+
+// LiteralKind is the kind of a [Literal].
+type LiteralKind string
+
+const (
+	// LiteralValue is a plain value.
+	LiteralValue LiteralKind = "value"
+	// LiteralExpression is a Nix expression given by lib.literalExpression,
+	// or by the older lib.literalExample.
+	LiteralExpression LiteralKind = "literalExpression"
+	// LiteralMarkdown is Markdown text given by lib.literalMD, or by the
+	// older lib.mdDoc and lib.literalDocBook.
+	LiteralMarkdown LiteralKind = "literalMD"
+	// LiteralUnserializable is a value that couldn't be converted to JSON,
+	// such as a function ("lambda"), a derivation ("derivation") or a value
+	// that throws an error ("error"). Nix gives it as an object with
+	// _unserializable set to the reason.
+	LiteralUnserializable LiteralKind = "unserializable"
+)
+
+// literalKinds maps the _type of Nix literals to their kinds.
+var literalKinds = map[string]LiteralKind{
+	"literalExpression": LiteralExpression,
+	"literalExample":    LiteralExpression,
+	"literalMD":         LiteralMarkdown,
+	"mdDoc":             LiteralMarkdown,
+	"literalDocBook":    LiteralMarkdown,
+}
+
+// IsZero returns true if the literal isn't set.
+func (l Literal) IsZero() bool { return l.Kind == "" }
+
+// String returns the text of the literal, or the JSON of plain values.
+func (l Literal) String() string {
+	if l.Kind == LiteralValue {
+		return string(l.Value)
+	}
+	return l.Text
+}
+
+// MarshalJSON implements [json.MarshalerV1]. Nix expressions and Markdown text
+// are marshaled the same way Nix does, as an object with _type and text.
+func (l Literal) MarshalJSON() ([]byte, error) {
+	switch l.Kind {
+	case LiteralValue:
+		if len(l.Value) == 0 {
+			return []byte("null"), nil
+		}
+		return l.Value, nil
+	case LiteralUnserializable:
+		return json.Marshal(struct {
+			Reason string `json:"_unserializable"`
+		}{
+			Reason: l.Text,
+		})
+	default:
+		return json.Marshal(struct {
+			Type string `json:"_type"`
+			Text string `json:"text"`
+		}{
+			Type: string(l.Kind),
+			Text: l.Text,
+		})
+	}
+}
+
+// UnmarshalJSON implements [json.UnmarshalerV1].
+func (l *Literal) UnmarshalJSON(data []byte) error {
+	var literal struct {
+		Type           string  `json:"_type"`
+		Text           *string `json:"text"`
+		Unserializable *string `json:"_unserializable"`
+	}
+	if jsontext.Value(data).Kind() == '{' && json.Unmarshal(data, &literal) == nil {
+		if literal.Unserializable != nil {
+			*l = Literal{Kind: LiteralUnserializable, Text: *literal.Unserializable}
+			return nil
+		}
+		if kind, ok := literalKinds[literal.Type]; ok && literal.Text != nil {
+			*l = Literal{Kind: kind, Text: *literal.Text}
+			return nil
+		}
+	}
+
+	value := jsontext.Value(bytes.Clone(data))
+	if err := value.Compact(); err != nil {
+		return err
+	}
+
+	*l = Literal{Kind: LiteralValue, Value: value}
+	return nil
+}
@@ -0,0 +1,81 @@
+package nixmodule
+
+import (
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLiteralJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Literal
+		// back is the marshaled JSON, if it differs from json.
+		back string
+	}{
+		{
+			name: "value",
+			json: `{ "a": [1, 2] }`,
+			want: Literal{Kind: LiteralValue, Value: jsontext.Value(`{"a":[1,2]}`)},
+			back: `{"a":[1,2]}`,
+		},
+		{
+			name: "null",
+			json: `null`,
+			want: Literal{Kind: LiteralValue, Value: jsontext.Value(`null`)},
+		},
+		{
+			name: "expression",
+			json: `{"_type":"literalExpression","text":"pkgs.hello"}`,
+			want: Literal{Kind: LiteralExpression, Text: "pkgs.hello"},
+		},
+		{
+			name: "markdown",
+			json: `{"_type":"literalMD","text":"*Hello*"}`,
+			want: Literal{Kind: LiteralMarkdown, Text: "*Hello*"},
+		},
+		{
+			name: "old expression",
+			json: `{"_type":"literalExample","text":"pkgs.hello"}`,
+			want: Literal{Kind: LiteralExpression, Text: "pkgs.hello"},
+			back: `{"_type":"literalExpression","text":"pkgs.hello"}`,
+		},
+		{
+			name: "unserializable",
+			json: `{"_unserializable":"lambda"}`,
+			want: Literal{Kind: LiteralUnserializable, Text: "lambda"},
+		},
+		{
+			name: "unknown type",
+			json: `{"_type":"other","text":"hello"}`,
+			want: Literal{Kind: LiteralValue, Value: jsontext.Value(`{"_type":"other","text":"hello"}`)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Literal
+			if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+				t.Fatal("cannot unmarshal:", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected literal (-want +got):\n%s", diff)
+			}
+
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal("cannot marshal:", err)
+			}
+			back := test.back
+			if back == "" {
+				back = test.json
+			}
+			if string(b) != back {
+				t.Errorf("unexpected JSON: got %s, want %s", b, back)
+			}
+		})
+	}
+}
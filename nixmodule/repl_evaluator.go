@@ -0,0 +1,331 @@
+package nixmodule
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// NixRepl is an [Evaluator] that keeps a single `nix repl` process running
+// and sends every parse check and evaluation to it, so that Nix is only
+// started once. The process is started on first use and is stopped by
+// [NixRepl.Close].
+//
+// NixRepl is safe for concurrent use, but requests are evaluated one at a
+// time. Expressions are written to temporary files before being evaluated, so
+// relative paths within them are resolved against a temporary directory and
+// should be avoided.
+type NixRepl struct {
+	// Path is the path to the nix binary.
+	// By default, it's looked up in $PATH.
+	Path string
+	// ExtraArgs are additional arguments given to `nix repl`.
+	ExtraArgs []string
+
+	mu      sync.Mutex
+	session *replSession
+	n       int
+}
+
+var _ Evaluator = (*NixRepl)(nil)
+
+// The markers are split in two within the commands, so that they aren't
+// mistaken for output if the input is echoed.
+const (
+	replResultMarker   = "nixmod2go-result:"
+	replSentinelPrefix = "nixmod2go-done-"
+)
+
+// splitNixString returns a Nix expression that concatenates s from two
+// halves.
+func splitNixString(s string) string {
+	return fmt.Sprintf("(%q + %q)", s[:len(s)/2], s[len(s)/2:])
+}
+
+// Parse implements [Evaluator].
+func (r *NixRepl) Parse(ctx context.Context, expr NixExpr) error {
+	// Importing a file parses it, including checking for undefined
+	// variables, and wrapping the expression in a function keeps it from
+	// being evaluated.
+	_, err := r.do(ctx, NixExpr(fmt.Sprintf("_: (%s\n)", expr)), nil,
+		func(file string) string {
+			return fmt.Sprintf("builtins.seq (import %s) null", file)
+		})
+	return err
+}
+
+// Eval implements [Evaluator].
+func (r *NixRepl) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	out, err := r.do(ctx, req.CallExpr(), req.Stderr,
+		func(file string) string {
+			return fmt.Sprintf("%s + builtins.toJSON (import %s)", splitNixString(replResultMarker), file)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range out {
+		// The line may be prefixed by the prompt.
+		_, result, ok := strings.Cut(line, `"`+replResultMarker)
+		if !ok {
+			continue
+		}
+		json, err := unquoteNixString(`"` + replResultMarker + strings.TrimSpace(result))
+		if err != nil {
+			return nil, fmt.Errorf("nix repl: %w", err)
+		}
+		return jsontext.Value(strings.TrimPrefix(json, replResultMarker)), nil
+	}
+
+	return nil, fmt.Errorf("nix repl: no result in output %q", strings.Join(out, "\n"))
+}
+
+// Close stops the `nix repl` process, if it's running.
+func (r *NixRepl) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session == nil {
+		return nil
+	}
+
+	err := r.session.close()
+	r.session = nil
+	return err
+}
+
+// do writes expr to a file and evaluates the line returned by command within
+// the session. It returns the standard output lines of the command.
+func (r *NixRepl) do(ctx context.Context, expr NixExpr, stderr io.Writer, command func(file string) string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session == nil {
+		s, err := r.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.session = s
+	}
+
+	r.n++
+
+	// Every request gets its own file, since Nix caches imports by path.
+	file := filepath.Join(r.session.dir, fmt.Sprintf("expr-%d.nix", r.n))
+	if err := os.WriteFile(file, []byte(expr), 0o644); err != nil {
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+	defer os.Remove(file)
+
+	out, errOut, err := r.session.run(ctx, command(file), r.n)
+	if err != nil {
+		// The session is in an unknown state, so start a new one next time.
+		r.session.close()
+		r.session = nil
+		return nil, err
+	}
+
+	if stderr != nil {
+		for _, line := range errOut {
+			fmt.Fprintln(stderr, line)
+		}
+	}
+
+	output := strings.Join(errOut, "\n")
+	if evalErr := parseEvalError(output); evalErr != nil {
+		return nil, evalErr
+	}
+
+	return out, nil
+}
+
+func (r *NixRepl) start(ctx context.Context) (*replSession, error) {
+	path := r.Path
+	if path == "" {
+		path = "nix"
+	}
+
+	args := slices.Concat(
+		[]string{"repl", "--extra-experimental-features", "nix-command flakes"},
+		r.ExtraArgs,
+		[]string{"--show-trace"},
+	)
+
+	dir, err := os.MkdirTemp("", "nixmod2go-repl-")
+	if err != nil {
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+
+	slog.DebugContext(ctx,
+		"starting nix repl",
+		"name", path,
+		"args", args)
+
+	// The process must outlive the request that started it, so it's not
+	// bound to ctx.
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), "NO_COLOR=1")
+
+	s := &replSession{cmd: cmd, dir: dir}
+
+	if s.stdin, err = cmd.StdinPipe(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("nix repl: %w", err)
+	}
+
+	s.stdout = readLines(stdout)
+	s.stderr = readLines(stderr)
+
+	// Skip the welcome message.
+	if _, _, err := s.run(ctx, "null", 0); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// replSession is a running `nix repl` process.
+type replSession struct {
+	cmd    *exec.Cmd
+	dir    string
+	stdin  io.WriteCloser
+	stdout <-chan string
+	stderr <-chan string
+}
+
+// run sends line to the repl, followed by a sentinel that is printed to both
+// standard output and standard error, and returns the lines of each up to the
+// sentinel.
+func (s *replSession) run(ctx context.Context, line string, n int) (stdout, stderr []string, err error) {
+	sentinel := fmt.Sprintf("%s%d.", replSentinelPrefix, n)
+
+	_, err = fmt.Fprintf(s.stdin, "%s\nbuiltins.trace %[2]s %[2]s\n", line, splitNixString(sentinel))
+	if err != nil {
+		return nil, nil, fmt.Errorf("nix repl: %w", err)
+	}
+
+	// Both streams must be read at the same time, otherwise Nix may block
+	// on writing to one of them.
+	var stderrErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		stderr, stderrErr = readUntil(ctx, s.stderr, sentinel)
+	}()
+
+	stdout, err = readUntil(ctx, s.stdout, sentinel)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+	if stderrErr != nil {
+		return nil, nil, stderrErr
+	}
+	return stdout, stderr, nil
+}
+
+func (s *replSession) close() error {
+	s.stdin.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	return os.RemoveAll(s.dir)
+}
+
+// readLines sends the lines of r to the returned channel until r is closed.
+func readLines(r io.Reader) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				ch <- strings.TrimRight(line, "\r\n")
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// readUntil reads lines from ch until a line containing sentinel is read.
+func readUntil(ctx context.Context, ch <-chan string, sentinel string) ([]string, error) {
+	var lines []string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case line, ok := <-ch:
+			if !ok {
+				return nil, fmt.Errorf("nix repl: process exited: %s", strings.Join(lines, "\n"))
+			}
+			if strings.Contains(line, sentinel) {
+				return lines, nil
+			}
+			lines = append(lines, line)
+		}
+	}
+}
+
+// unquoteNixString unquotes a double-quoted Nix string as printed by Nix.
+func unquoteNixString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("invalid Nix string")
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("invalid Nix string: trailing backslash")
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			// \", \\ and \$.
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
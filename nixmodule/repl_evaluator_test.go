@@ -0,0 +1,29 @@
+package nixmodule
+
+import "testing"
+
+func TestUnquoteNixString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: `""`, want: ``},
+		{in: `"hello"`, want: `hello`},
+		{in: `"{\"a\":\"b\\\\c\"}"`, want: `{"a":"b\\c"}`},
+		{in: `"\${x}\n\t"`, want: "${x}\n\t"},
+		{in: `hello`, err: true},
+		{in: `"\"`, err: true},
+	}
+
+	for _, test := range tests {
+		got, err := unquoteNixString(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("unquoteNixString(%q): unexpected error %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("unquoteNixString(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
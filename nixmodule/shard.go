@@ -0,0 +1,140 @@
+package nixmodule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type sharding struct {
+	depth   int
+	workers int
+}
+
+// DumpModuleWithShards splits the option tree into shards and dumps them in
+// parallel, using up to workers evaluations at a time. If workers is zero or
+// less, the number of CPUs is used.
+//
+// With a depth of 1, every top-level option and option namespace is its own
+// shard. With a depth of 2, namespaces are split once more, and so on. Shards
+// are relative to [DumpModuleWithOptionsPath].
+//
+// Sharding is useful for large option trees, such as the ones of NixOS, since
+// each shard is evaluated by its own, smaller Nix evaluation. Failed shards are
+// reported as [*ShardError]s.
+func DumpModuleWithShards(depth, workers int) DumpModuleOpt {
+	return dumpModuleOptFunc(func(ctx context.Context, s *dumpModuleState) error {
+		if depth < 1 {
+			return fmt.Errorf("shard depth must be at least 1, got %d", depth)
+		}
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		s.sharding = &sharding{depth, workers}
+		return nil
+	})
+}
+
+// ShardError is the error of a single shard dumped by [DumpModule] when
+// [DumpModuleWithShards] is used.
+type ShardError struct {
+	// Path is the option path of the shard's namespace.
+	Path []string
+	// Names are the names of the options within Path that the shard contains.
+	Names []string
+	// Err is the error that occurred while dumping the shard.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ShardError) Error() string {
+	names := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		names[i] = strings.Join(append(e.Path[:len(e.Path):len(e.Path)], name), ".")
+	}
+	return fmt.Sprintf("shard %s: %v", strings.Join(names, ", "), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
+
+// dumpModuleShard is a shard as listed by dump_module.nix.
+type dumpModuleShard struct {
+	// Path is the absolute option path of the shard's namespace.
+	Path []string `json:"path"`
+	// Names are the names of the options within Path.
+	Names []string `json:"names"`
+}
+
+func dumpModuleShards(ctx context.Context, s *dumpModuleState) (Module, error) {
+	listReq := s.request
+	listReq.Args = maps.Clone(s.request.Args)
+	listReq.Args["shardDepth"] = NixExpr(strconv.Itoa(s.sharding.depth))
+
+	shards, err := evalDumpModule[[]dumpModuleShard](ctx, s, listReq)
+	if err != nil {
+		return nil, fmt.Errorf("list shards: %w", err)
+	}
+
+	slog.DebugContext(ctx,
+		"dumping module in shards",
+		"shards", len(shards),
+		"workers", s.sharding.workers)
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		// errs is indexed by shard, so that errors are in a stable order.
+		errs = make([]error, len(shards))
+		root = Module{}
+		sem  = make(chan struct{}, s.sharding.workers)
+	)
+
+	for i, shard := range shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			req := s.request
+			req.Args = maps.Clone(s.request.Args)
+			req.Args["optionsPath"] = nixStringList(shard.Path)
+			req.Args["optionNames"] = nixStringList(shard.Names)
+
+			m, err := evalDumpModule[Module](ctx, s, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs[i] = &ShardError{Path: shard.Path, Names: shard.Names, Err: err}
+				return
+			}
+
+			// Shard paths are absolute, but the dumped module is relative to
+			// the options path.
+			dst := root
+			for _, name := range shard.Path[len(s.optionsPath):] {
+				sub, ok := dst[name].(Module)
+				if !ok {
+					sub = Module{}
+					dst[name] = sub
+				}
+				dst = sub
+			}
+			maps.Copy(dst, m)
+		}()
+	}
+
+	wg.Wait()
+	return root, errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package nixmodule
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/go-cmp/cmp"
+)
+
+// evaluatorFunc is an Evaluator that evaluates using a function.
+type evaluatorFunc func(req EvalRequest) (jsontext.Value, error)
+
+func (f evaluatorFunc) Parse(ctx context.Context, expr NixExpr) error { return nil }
+
+func (f evaluatorFunc) Eval(ctx context.Context, req EvalRequest) (jsontext.Value, error) {
+	return f(req)
+}
+
+func TestDumpModuleWithShards(t *testing.T) {
+	ev := evaluatorFunc(func(req EvalRequest) (jsontext.Value, error) {
+		if req.Args["shardDepth"] == "2" {
+			return jsontext.Value(`[
+				{"path":["services"],"names":["a"]},
+				{"path":["services"],"names":["b"]},
+				{"path":[],"names":["enable"]}
+			]`), nil
+		}
+		switch req.Args["optionsPath"] + " " + req.Args["optionNames"] {
+		case `[ "services" ] [ "a" ]`:
+			return jsontext.Value(`{"a":{"_option":true,"_type":"str"}}`), nil
+		case `[ "services" ] [ "b" ]`:
+			return nil, errors.New("b is broken")
+		case `[ ] [ "enable" ]`:
+			return jsontext.Value(`{"enable":{"_option":true,"_type":"bool"}}`), nil
+		}
+		t.Fatalf("unexpected request args %v", req.Args)
+		return nil, nil
+	})
+
+	m, _, err := DumpModule(context.Background(), ModuleExpr(`{ }`),
+		DumpModuleWithEvaluator(ev),
+		DumpModuleWithShards(2, 2))
+
+	want := Module{
+		"enable": BoolOption{},
+		"services": Module{
+			"a": StrOption{},
+		},
+	}
+	if diff := cmp.Diff(want, m); diff != "" {
+		t.Fatalf("unexpected module (-want +got):\n%s", diff)
+	}
+
+	var shardErr *ShardError
+	assert.True(t, errors.As(err, &shardErr), "expected a ShardError, got %v", err)
+	assert.Equal(t, []string{"services"}, shardErr.Path)
+	assert.Equal(t, []string{"b"}, shardErr.Names)
+	assert.Equal(t, "shard services.b: b is broken", shardErr.Error())
+}
@@ -0,0 +1,9 @@
+{ lib, ... }:
+{
+  options = {
+    hello = lib.mkOption {
+      type = lib.types.str;
+      description = "Hello, world!";
+    };
+  };
+}
@@ -0,0 +1,67 @@
+package nixmodule
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+)
+
+// Visibility is whether an option is shown in the documentation, as given by
+// the visible attribute of mkOption. The zero value means the attribute isn't
+// set, which is the same as [VisibilityVisible].
+type Visibility string
+
+const (
+	// VisibilityVisible shows the option and its sub-options (visible = true).
+	VisibilityVisible Visibility = "visible"
+	// VisibilityHidden hides the option and its sub-options (visible = false).
+	VisibilityHidden Visibility = "hidden"
+	// VisibilityShallow shows the option but hides its sub-options.
+	VisibilityShallow Visibility = "shallow"
+	// VisibilityTransparent hides the option but shows its sub-options.
+	VisibilityTransparent Visibility = "transparent"
+)
+
+// OptionVisible returns true if the option itself is shown.
+func (v Visibility) OptionVisible() bool {
+	return v != VisibilityHidden && v != VisibilityTransparent
+}
+
+// SubOptionsVisible returns true if the sub-options of the option, such as
+// the options of its submodule, are shown.
+func (v Visibility) SubOptionsVisible() bool {
+	return v != VisibilityHidden && v != VisibilityShallow
+}
+
+// MarshalJSON implements [json.MarshalerV1]. Visibilities are marshaled the
+// same way Nix does, as a boolean or a string.
+func (v Visibility) MarshalJSON() ([]byte, error) {
+	switch v {
+	case "", VisibilityVisible:
+		return []byte("true"), nil
+	case VisibilityHidden:
+		return []byte("false"), nil
+	default:
+		return json.Marshal(string(v))
+	}
+}
+
+// UnmarshalJSON implements [json.UnmarshalerV1].
+func (v *Visibility) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch value {
+	case true:
+		*v = VisibilityVisible
+	case false:
+		*v = VisibilityHidden
+	case string(VisibilityShallow), string(VisibilityTransparent):
+		*v = Visibility(value.(string))
+	default:
+		return fmt.Errorf("invalid visibility %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package nixmodule
+
+import (
+	"testing"
+
+	"github.com/go-json-experiment/json"
+)
+
+func TestVisibilityJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Visibility
+	}{
+		{"true", `true`, VisibilityVisible},
+		{"false", `false`, VisibilityHidden},
+		{"shallow", `"shallow"`, VisibilityShallow},
+		{"transparent", `"transparent"`, VisibilityTransparent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Visibility
+			if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+				t.Fatal("cannot unmarshal:", err)
+			}
+			if got != test.want {
+				t.Errorf("unexpected visibility: got %q, want %q", got, test.want)
+			}
+
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal("cannot marshal:", err)
+			}
+			if string(b) != test.json {
+				t.Errorf("unexpected JSON: got %s, want %s", b, test.json)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var got Visibility
+		if err := json.Unmarshal([]byte(`"hidden"`), &got); err == nil {
+			t.Errorf("expected an error, got %q", got)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		var doc OptionDoc
+		if err := json.Unmarshal([]byte(`{}`), &doc); err != nil {
+			t.Fatal("cannot unmarshal:", err)
+		}
+		if !doc.Visible.OptionVisible() || !doc.Visible.SubOptionsVisible() {
+			t.Errorf("unset visibility should be visible, got %q", doc.Visible)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package nixmodule
+
+import (
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// OptionPath is the path of an option within a module. Elements of listOf and
+// attrsOf options, as well as undeclared attributes of freeform submodules,
+// are denoted by "*" and "<name>", the same way as in [Warning.Path].
+type OptionPath []string
+
+// String joins the path with dots.
+func (p OptionPath) String() string {
+	return strings.Join(p, ".")
+}
+
+// add returns a new path with the given names appended.
+func (p OptionPath) add(names ...string) OptionPath {
+	return slices.Concat(p, names)
+}
+
+// SkipSubtree is returned by a [WalkFunc] to skip the options nested within
+// the option it was called with. It may be wrapped, and it is never returned
+// by [Walk].
+var SkipSubtree = errors.New("skip subtree")
+
+// WalkFunc is the function called by [Walk] for each option. The path may be
+// kept, since every call is given its own. If it returns an error that isn't
+// [SkipSubtree], walking stops and [Walk] returns that error.
+type WalkFunc func(path OptionPath, opt Option) error
+
+// Walk calls fn for every option within module, including nested modules and
+// the types nested within options, outermost first. Options are visited in
+// the order of their names, so the order is always the same.
+//
+// Types nested within an option, including the freeform type of submodules,
+// are given the same path as the option, except for the elements of listOf
+// and attrsOf options, whose paths end with "*" or "<name>". The options of
+// submodules are visited right after the submodule option, without the module
+// itself.
+func Walk(module Module, fn WalkFunc) error {
+	return walkModule(nil, module, fn)
+}
+
+func walkModule(path OptionPath, module Module, fn WalkFunc) error {
+	for _, name := range slices.Sorted(maps.Keys(module)) {
+		if err := walk(path.add(name), module[name], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walk(path OptionPath, o Option, fn WalkFunc) error {
+	if err := fn(path, o); err != nil {
+		if errors.Is(err, SkipSubtree) {
+			return nil
+		}
+		return err
+	}
+
+	each := func(path OptionPath, options ...Option) error {
+		for _, o := range options {
+			if err := walk(path, o, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch o := o.(type) {
+	case Module:
+		return walkModule(path, o, fn)
+	case SubmoduleOption:
+		if err := walkModule(path, o.Submodule, fn); err != nil {
+			return err
+		}
+		if o.Freeform != nil {
+			return each(path, o.Freeform)
+		}
+	case UniqueOption:
+		return each(path, o.Unique)
+	case NullOrOption:
+		return each(path, o.NullOr)
+	case ListOfOption:
+		return each(path.add("*"), o.ListOf)
+	case AttrsOfOption:
+		return each(path.add("<name>"), o.AtrrsOf)
+	case EitherOption:
+		return each(path, o.Either...)
+	case CoercedToOption:
+		return each(path, o.From, o.To)
+	case FunctionToOption:
+		return each(path, o.FunctionTo)
+	case UnspecifiedOption:
+		for _, name := range slices.Sorted(maps.Keys(o.NestedTypes)) {
+			if err := each(path, o.NestedTypes[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isLeaf returns true if no option is nested within o.
+func isLeaf(o Option) bool {
+	switch o := o.(type) {
+	case Module:
+		return len(o) == 0
+	case SubmoduleOption:
+		return len(o.Submodule) == 0 && o.Freeform == nil
+	case UnspecifiedOption:
+		return len(o.NestedTypes) == 0
+	case UniqueOption, NullOrOption, ListOfOption, AttrsOfOption,
+		EitherOption, CoercedToOption, FunctionToOption:
+		return false
+	default:
+		return true
+	}
+}
+
+// errStopAll stops walking once the loop over [Module.All] is broken out of.
+var errStopAll = errors.New("stop")
+
+// All returns an iterator over all options within the module, in the same
+// order as [Walk].
+func (m Module) All() iter.Seq2[OptionPath, Option] {
+	return func(yield func(OptionPath, Option) bool) {
+		Walk(m, func(path OptionPath, o Option) error {
+			if !yield(path, o) {
+				return errStopAll
+			}
+			return nil
+		})
+	}
+}
+
+// FlatOption is an option along with its full path, as returned by
+// [Module.Flatten].
+type FlatOption struct {
+	Path   OptionPath
+	Option Option
+}
+
+// Flatten returns all leaf options within the module, which are the options
+// that no other option is nested within, sorted by their paths. Options with
+// the same path, such as the types within an either option, are kept in the
+// order of [Walk].
+func (m Module) Flatten() []FlatOption {
+	var flat []FlatOption
+	for path, o := range m.All() {
+		if isLeaf(o) {
+			flat = append(flat, FlatOption{path, o})
+		}
+	}
+
+	slices.SortStableFunc(flat, func(a, b FlatOption) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+	return flat
+}
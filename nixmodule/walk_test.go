@@ -0,0 +1,139 @@
+package nixmodule
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var walkTestModule = Module{
+	"services": Module{
+		"web": SubmoduleOption{
+			Submodule: Module{
+				"port":  IntOption{},
+				"hosts": AttrsOfOption{AtrrsOf: ListOfOption{ListOf: StrOption{}}},
+			},
+			Freeform: AttrsOfOption{AtrrsOf: StrOption{}},
+		},
+	},
+	"name": NullOrOption{NullOr: StrOption{}},
+	"mode": EitherOption{Either: []Option{BoolOption{}, IntOption{}}},
+}
+
+func TestWalk(t *testing.T) {
+	type visit struct {
+		Path string
+		Type string
+	}
+
+	tests := []struct {
+		name string
+		skip string
+		want []visit
+	}{
+		{
+			name: "all",
+			want: []visit{
+				{"mode", "either"},
+				{"mode", "bool"},
+				{"mode", "int"},
+				{"name", "nullOr"},
+				{"name", "str"},
+				{"services", ""},
+				{"services.web", "submodule"},
+				{"services.web.hosts", "attrsOf"},
+				{"services.web.hosts.<name>", "listOf"},
+				{"services.web.hosts.<name>.*", "str"},
+				{"services.web.port", "int"},
+				{"services.web", "attrsOf"},
+				{"services.web.<name>", "str"},
+			},
+		},
+		{
+			name: "skip subtree",
+			skip: "services.web",
+			want: []visit{
+				{"mode", "either"},
+				{"mode", "bool"},
+				{"mode", "int"},
+				{"name", "nullOr"},
+				{"name", "str"},
+				{"services", ""},
+				{"services.web", "submodule"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []visit
+			err := Walk(walkTestModule, func(path OptionPath, o Option) error {
+				got = append(got, visit{path.String(), o.Type()})
+				if path.String() == test.skip {
+					return fmt.Errorf("skipping %s: %w", path, SkipSubtree)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected visits (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		errTest := errors.New("test")
+		var n int
+		err := Walk(walkTestModule, func(path OptionPath, o Option) error {
+			n++
+			return errTest
+		})
+		if err != errTest {
+			t.Errorf("unexpected error: got %v, want %v", err, errTest)
+		}
+		if n != 1 {
+			t.Errorf("walking didn't stop: fn called %d times", n)
+		}
+	})
+}
+
+func TestModuleAll(t *testing.T) {
+	var got []string
+	for path := range walkTestModule.All() {
+		got = append(got, path.String())
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []string{"mode", "mode", "mode"}; !cmp.Equal(want, got) {
+		t.Errorf("unexpected paths: got %q, want %q", got, want)
+	}
+}
+
+func TestModuleFlatten(t *testing.T) {
+	type leaf struct {
+		Path string
+		Type string
+	}
+
+	var got []leaf
+	for _, flat := range walkTestModule.Flatten() {
+		got = append(got, leaf{flat.Path.String(), flat.Option.Type()})
+	}
+
+	want := []leaf{
+		{"mode", "bool"},
+		{"mode", "int"},
+		{"name", "str"},
+		{"services.web.<name>", "str"},
+		{"services.web.hosts.<name>.*", "str"},
+		{"services.web.port", "int"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected leaves (-want +got):\n%s", diff)
+	}
+}